@@ -3,12 +3,15 @@ package cmd
 import (
 	"encoding/csv"
 	"fmt"
+	"os"
 	"path"
+	"strconv"
 	"strings"
 
 	"github.com/dnephin/pflag"
 	"github.com/google/shlex"
 	"gotest.tools/gotestsum/internal/junitxml"
+	"gotest.tools/gotestsum/internal/log"
 	"gotest.tools/gotestsum/testjson"
 )
 
@@ -140,3 +143,19 @@ func truthyFlag(s string) bool {
 	}
 	return false
 }
+
+// intFlag returns the value of the environment variable key as an int, or 0
+// if it is not set. A value that is not a number is ignored with a warning,
+// so that a typo is not silently used as 0.
+func intFlag(key string) int {
+	s := strings.TrimSpace(os.Getenv(key))
+	if s == "" {
+		return 0
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		log.Warnf("Ignoring invalid value %q of %v, the value must be a number", s, key)
+		return 0
+	}
+	return n
+}
@@ -33,3 +33,13 @@ func TestStringSlice(t *testing.T) {
 	assert.NilError(t, ss.Set(value))
 	assert.DeepEqual(t, v, []string{"one", "two", "three", "four", "five"})
 }
+
+func TestIntFlag(t *testing.T) {
+	key := "GOTESTSUM_TEST_INT_FLAG"
+	t.Setenv(key, " 65536 ")
+	assert.Equal(t, intFlag(key), 65536)
+	t.Setenv(key, "")
+	assert.Equal(t, intFlag(key), 0)
+	t.Setenv(key, "10k")
+	assert.Equal(t, intFlag(key), 0)
+}
@@ -0,0 +1,167 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"gotest.tools/gotestsum/testjson"
+)
+
+// runDetectFlaky runs the tests opts.detectFlaky times, with a different RunID
+// for each run, and then reports the tests which passed in some runs and
+// failed in others.
+func runDetectFlaky(ctx context.Context, opts *options) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	handler, err := newEventHandler(opts)
+	if err != nil {
+		return err
+	}
+	defer handler.Close() //nolint:errcheck
+	handler.flaky = &flakyRuns{}
+
+	var exec *testjson.Execution
+	var exitErr error
+	for runID := 0; runID < opts.detectFlaky; runID++ {
+		if runID > 0 {
+			testjson.PrintSummary(opts.stdout, exec, testjson.SummarizeNone)
+			opts.stdout.Write([]byte("\n")) //nolint:errcheck
+		}
+
+		goTestProc, err := startGoTestFn(ctx, "", goTestStdin(opts), goTestCmdArgs(opts, rerunOpts{}))
+		if err != nil {
+			return err
+		}
+		cfg := testjson.ScanConfig{
+			RunID:                    runID,
+			Stdout:                   goTestProc.stdout,
+			Stderr:                   goTestProc.stderr,
+			Handler:                  handler,
+			Execution:                exec,
+			Stop:                     cancel,
+			IgnoreNonJSONOutputLines: opts.ignoreNonJSONOutputLines,
+			KeepPassedOutput:         opts.keepPassedOutput(),
+		}
+		exec, err = testjson.ScanTestOutput(cfg)
+		handler.Flush()
+		if err != nil {
+			return finishRun(opts, handler, exec, err)
+		}
+
+		runErr := goTestProc.cmd.Wait()
+		if signum := atomic.LoadInt32(&goTestProc.signal); signum != 0 {
+			return finishRun(opts, handler, exec, exitError{num: signalExitCode + int(signum)})
+		}
+		handler.flaky.addRun(exec)
+		switch {
+		case len(exec.Errors()) > 0:
+			err := fmt.Errorf("flaky test detection aborted because run %d had errors", runID+1)
+			return finishRun(opts, handler, exec, err)
+		// Exit code 0 and 1 are expected.
+		case ExitCodeWithDefault(runErr) > 1:
+			return finishRun(opts, handler, exec, fmt.Errorf("unexpected go test exit code: %v", runErr))
+		case runErr != nil:
+			exitErr = runErr
+		}
+	}
+	return finishRun(opts, handler, exec, exitErr)
+}
+
+// flakyRuns records the runs of --detect-flaky.
+type flakyRuns struct {
+	// seeds is the shuffle seed of each package, indexed by RunID. The seed
+	// is empty when the tests were not run with -shuffle.
+	seeds []map[string]string
+}
+
+func (r *flakyRuns) addRun(exec *testjson.Execution) {
+	seeds := make(map[string]string)
+	for _, name := range exec.Packages() {
+		seeds[name] = exec.Package(name).ShuffleSeed()
+	}
+	r.seeds = append(r.seeds, seeds)
+}
+
+func (r *flakyRuns) seed(runID int, pkg string) string {
+	if runID < 0 || runID >= len(r.seeds) {
+		return ""
+	}
+	return r.seeds[runID][pkg]
+}
+
+type flakyTest struct {
+	name   string
+	passed int
+	failed int
+	// seeds are the shuffle seeds of the runs where the test failed.
+	seeds []string
+}
+
+// flakyTests returns the tests which passed in at least one run, and failed in
+// at least one run, sorted by name.
+func (r *flakyRuns) flakyTests(exec *testjson.Execution) []flakyTest {
+	var result []flakyTest
+	for _, pkgName := range exec.Packages() {
+		pkg := exec.Package(pkgName)
+		tests := make(map[testjson.TestName]*flakyTest)
+		get := func(name testjson.TestName) *flakyTest {
+			if _, ok := tests[name]; !ok {
+				tests[name] = &flakyTest{name: pkgName + "." + name.Name()}
+			}
+			return tests[name]
+		}
+
+		for _, tc := range pkg.Passed {
+			get(tc.Test).passed++
+		}
+		for _, tc := range pkg.Failed {
+			test := get(tc.Test)
+			test.failed++
+			if seed := r.seed(tc.RunID, pkgName); seed != "" && !slices.Contains(test.seeds, seed) {
+				test.seeds = append(test.seeds, seed)
+			}
+		}
+		// Skipped tests are not counted, a test which is skipped in some runs
+		// is not flaky.
+
+		for _, test := range tests {
+			if test.passed > 0 && test.failed > 0 {
+				result = append(result, *test)
+			}
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].name < result[j].name
+	})
+	return result
+}
+
+// printReport prints the flaky tests with their pass rate, and the -shuffle
+// seeds that reproduced the failure. printReport does nothing when r is nil.
+func (r *flakyRuns) printReport(out io.Writer, exec *testjson.Execution) {
+	if r == nil || exec == nil {
+		return
+	}
+	tests := r.flakyTests(exec)
+	if len(tests) == 0 {
+		fmt.Fprintf(out, "\nNo flaky tests found in %d runs\n", len(r.seeds))
+		return
+	}
+
+	fmt.Fprintf(out, "\n=== Flaky tests (%d runs)\n", len(r.seeds))
+	for _, test := range tests {
+		total := test.passed + test.failed
+		fmt.Fprintf(out, "%s: %d runs, %d failures, %.0f%% passed",
+			test.name, total, test.failed, float64(test.passed)/float64(total)*100)
+		if len(test.seeds) > 0 {
+			fmt.Fprintf(out, ", failed with -shuffle=%s", strings.Join(test.seeds, ", -shuffle="))
+		}
+		fmt.Fprintln(out)
+	}
+}
@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestRun_DetectFlaky(t *testing.T) {
+	results := []struct {
+		seed    int
+		testOne string
+		exitErr error
+	}{
+		{seed: 101, testOne: "pass"},
+		{seed: 102, testOne: "fail", exitErr: newExitCode("run-failed-2", 1)},
+		{seed: 103, testOne: "fail", exitErr: newExitCode("run-failed-3", 1)},
+		{seed: 104, testOne: "pass"},
+	}
+	var runs int
+	fn := func(args []string) *proc {
+		assert.DeepEqual(t, args, []string{"go", "test", "-json", "-shuffle=on", "./..."})
+		r := results[runs]
+		runs++
+		return &proc{
+			cmd: fakeWaiter{result: r.exitErr},
+			stdout: strings.NewReader(fmt.Sprintf(dedentOutput(`
+				{"Package": "pkg", "Action": "start"}
+				{"Package": "pkg", "Action": "output", "Output": "-test.shuffle %d\n"}
+				{"Package": "pkg", "Test": "TestOne", "Action": "run"}
+				{"Package": "pkg", "Test": "TestOne", "Action": "%s"}
+				{"Package": "pkg", "Test": "TestTwo", "Action": "run"}
+				{"Package": "pkg", "Test": "TestTwo", "Action": "fail"}
+				{"Package": "pkg", "Test": "TestThree", "Action": "run"}
+				{"Package": "pkg", "Test": "TestThree", "Action": "pass"}
+				{"Package": "pkg", "Action": "fail"}
+			`), r.seed, r.testOne)),
+			stderr: bytes.NewReader(nil),
+		}
+	}
+	reset := patchStartGoTestFn(fn)
+	defer reset()
+
+	out := new(bytes.Buffer)
+	opts := &options{
+		args:        []string{"-shuffle=on", "./..."},
+		format:      "testname",
+		detectFlaky: 4,
+		stdout:      out,
+		stderr:      os.Stderr,
+		hideSummary: newHideSummaryValue(),
+	}
+	err := run(opts)
+	assert.Error(t, err, "run-failed-3")
+	assert.Equal(t, runs, 4)
+
+	expected := `
+=== Flaky tests (4 runs)
+pkg.TestOne: 4 runs, 2 failures, 50% passed, failed with -shuffle=102, -shuffle=103
+`
+	assert.Assert(t, strings.HasSuffix(out.String(), expected), out.String())
+}
+
+func TestRun_DetectFlaky_NoFlakyTests(t *testing.T) {
+	fn := func([]string) *proc {
+		return &proc{
+			cmd: fakeWaiter{},
+			stdout: strings.NewReader(dedentOutput(`
+				{"Package": "pkg", "Test": "TestOne", "Action": "run"}
+				{"Package": "pkg", "Test": "TestOne", "Action": "pass"}
+				{"Package": "pkg", "Action": "pass"}
+			`)),
+			stderr: bytes.NewReader(nil),
+		}
+	}
+	reset := patchStartGoTestFn(fn)
+	defer reset()
+
+	out := new(bytes.Buffer)
+	opts := &options{
+		format:      "testname",
+		detectFlaky: 2,
+		stdout:      out,
+		stderr:      os.Stderr,
+		hideSummary: newHideSummaryValue(),
+	}
+	err := run(opts)
+	assert.NilError(t, err)
+	assert.Assert(t, strings.HasSuffix(out.String(), "\nNo flaky tests found in 2 runs\n"), out.String())
+}
@@ -0,0 +1,226 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/google/shlex"
+	"gotest.tools/gotestsum/internal/log"
+	"gotest.tools/gotestsum/testjson"
+)
+
+// formatPluginProtocol identifies the version of the protocol used to send
+// events to a format plugin.
+//
+// gotestsum starts the plugin and writes a formatPluginHello as the first line
+// to its stdin. The plugin must respond by writing formatPluginProtocol as the
+// first line to its stdout. Every event is then written to stdin as a line of
+// JSON (formatPluginEvent). Everything else the plugin writes to stdout and
+// stderr is written to the stdout and stderr of gotestsum. When all events
+// have been sent, gotestsum closes stdin and waits for the plugin to exit.
+const formatPluginProtocol = "gotestsum-format/1"
+
+var formatPluginHandshakeTimeout = 30 * time.Second
+
+type formatPluginHello struct {
+	Protocol string
+	Version  string
+}
+
+// formatPluginEvent is a testjson.TestEvent with some of the state computed
+// by gotestsum from previous events.
+type formatPluginEvent struct {
+	testjson.TestEvent
+	IsSubTest bool
+	// Package contains the counts for the package of the event, including this
+	// event.
+	PackageCounts formatPluginCounts
+	// Counts contains the counts for all the packages, including this event.
+	Counts formatPluginCounts
+}
+
+type formatPluginCounts struct {
+	Total   int
+	Passed  int
+	Failed  int
+	Skipped int
+}
+
+type formatPlugin struct {
+	command string
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	enc     *json.Encoder
+	// copied receives the error from copying the stdout of the plugin, once the
+	// plugin closes its stdout.
+	copied chan error
+	exited bool
+	err    error
+
+	// counts for all packages, updated from the counts of the package of each
+	// event, so that the totals are not recomputed from every package.
+	counts formatPluginCounts
+	// packages contains the counts of each package that were last added to
+	// counts.
+	packages map[string]formatPluginCounts
+}
+
+// newFormatPlugin starts the plugin command and performs the handshake.
+func newFormatPlugin(command string, stdout, stderr io.Writer) (*formatPlugin, error) {
+	args, err := shlex.Split(command)
+	if err != nil {
+		return nil, fmt.Errorf("invalid format plugin command %q: %w", command, err)
+	}
+	if len(args) == 0 {
+		return nil, fmt.Errorf("format plugin command is required, use --format=exec:<command>")
+	}
+	log.Debugf("exec: %s", args)
+
+	p := &formatPlugin{
+		command: command,
+		cmd:     exec.Command(args[0], args[1:]...),
+		copied:  make(chan error, 1),
+
+		packages: make(map[string]formatPluginCounts),
+	}
+	p.cmd.Stderr = stderr
+	p.cmd.Env = append(os.Environ(), "GOTESTSUM_FORMAT_PROTOCOL="+formatPluginProtocol)
+	if p.stdin, err = p.cmd.StdinPipe(); err != nil {
+		return nil, fmt.Errorf("failed to start format plugin: %w", err)
+	}
+	pluginStdout, err := p.cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start format plugin: %w", err)
+	}
+	if err := p.cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start format plugin %q: %w", command, err)
+	}
+	p.enc = json.NewEncoder(p.stdin)
+
+	reader := bufio.NewReader(pluginStdout)
+	if err := p.handshake(reader); err != nil {
+		_ = p.cmd.Process.Kill()
+		_ = p.cmd.Wait()
+		return nil, err
+	}
+	go func() {
+		_, err := io.Copy(stdout, reader)
+		p.copied <- err
+	}()
+	return p, nil
+}
+
+func (p *formatPlugin) handshake(reader *bufio.Reader) error {
+	if err := p.enc.Encode(formatPluginHello{Protocol: formatPluginProtocol, Version: version}); err != nil {
+		return fmt.Errorf("format plugin %q handshake failed: %w", p.command, err)
+	}
+
+	type result struct {
+		line string
+		err  error
+	}
+	ch := make(chan result, 1)
+	go func() {
+		line, err := reader.ReadString('\n')
+		ch <- result{line: line, err: err}
+	}()
+
+	var res result
+	select {
+	case res = <-ch:
+	case <-time.After(formatPluginHandshakeTimeout):
+		return fmt.Errorf("format plugin %q handshake failed: timeout after %v waiting for response",
+			p.command, formatPluginHandshakeTimeout)
+	}
+	line := strings.TrimRight(res.line, "\r\n")
+	switch {
+	case line == formatPluginProtocol:
+		return nil
+	case res.err != nil:
+		return fmt.Errorf("format plugin %q handshake failed: plugin exited without a response", p.command)
+	default:
+		return fmt.Errorf("format plugin %q handshake failed: expected %q, got %q",
+			p.command, formatPluginProtocol, line)
+	}
+}
+
+func (p *formatPlugin) Format(event testjson.TestEvent, exec *testjson.Execution) error {
+	pkg := exec.Package(event.Package)
+	msg := formatPluginEvent{
+		TestEvent:     event,
+		IsSubTest:     testjson.TestName(event.Test).IsSubTest(),
+		PackageCounts: packageCounts(pkg),
+		Counts:        p.updateCounts(event.Package, pkg),
+	}
+
+	if err := p.enc.Encode(msg); err != nil {
+		if waitErr := p.wait(); waitErr != nil {
+			return waitErr
+		}
+		return fmt.Errorf("format plugin %q exited before all events were sent", p.command)
+	}
+	return nil
+}
+
+// packageCounts returns the counts of the package. Like Execution.Failed, a
+// package that failed without any failed tests is counted as a failure.
+func packageCounts(pkg *testjson.Package) formatPluginCounts {
+	if pkg == nil {
+		return formatPluginCounts{}
+	}
+	counts := formatPluginCounts{
+		Total:   pkg.Total,
+		Passed:  len(pkg.Passed),
+		Failed:  len(pkg.Failed),
+		Skipped: len(pkg.Skipped),
+	}
+	if pkg.TestMainFailed() {
+		counts.Failed++
+	}
+	return counts
+}
+
+// updateCounts replaces the previous counts of the package in p.counts with
+// the current counts, and returns the new counts for all packages.
+func (p *formatPlugin) updateCounts(name string, pkg *testjson.Package) formatPluginCounts {
+	counts := packageCounts(pkg)
+	prev := p.packages[name]
+	p.counts.Total += counts.Total - prev.Total
+	p.counts.Passed += counts.Passed - prev.Passed
+	p.counts.Failed += counts.Failed - prev.Failed
+	p.counts.Skipped += counts.Skipped - prev.Skipped
+	p.packages[name] = counts
+	return p.counts
+}
+
+// Close stdin of the plugin, and wait for it to exit. Returns an error if
+// the plugin exited with a non-zero exit code.
+func (p *formatPlugin) Close() error {
+	if err := p.stdin.Close(); err != nil && !errors.Is(err, os.ErrClosed) {
+		log.Debugf("failed to close stdin of format plugin: %v", err)
+	}
+	return p.wait()
+}
+
+func (p *formatPlugin) wait() error {
+	if p.exited {
+		return p.err
+	}
+	p.exited = true
+	copyErr := <-p.copied
+	if err := p.cmd.Wait(); err != nil {
+		p.err = fmt.Errorf("format plugin %q failed: %w", p.command, err)
+		return p.err
+	}
+	if copyErr != nil {
+		p.err = fmt.Errorf("failed to copy output of format plugin: %w", copyErr)
+	}
+	return p.err
+}
@@ -2,23 +2,41 @@ package cmd
 
 import (
 	"bufio"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
+	"time"
 
+	"gotest.tools/gotestsum/internal/allure"
+	"gotest.tools/gotestsum/internal/chrometrace"
+	"gotest.tools/gotestsum/internal/ctrf"
+	"gotest.tools/gotestsum/internal/htmlreport"
 	"gotest.tools/gotestsum/internal/junitxml"
 	"gotest.tools/gotestsum/internal/log"
+	"gotest.tools/gotestsum/internal/nunitxml"
+	"gotest.tools/gotestsum/internal/quarantine"
+	"gotest.tools/gotestsum/internal/sonarxml"
+	"gotest.tools/gotestsum/internal/testlocation"
+	"gotest.tools/gotestsum/internal/xunitxml"
 	"gotest.tools/gotestsum/testjson"
 )
 
 type eventHandler struct {
 	formatter            testjson.EventFormatter
+	formatterClosed      bool
 	err                  *bufio.Writer
 	jsonFile             writeSyncer
 	jsonFileTimingEvents writeSyncer
 	maxFails             int
+	summary              *testjson.SummaryTemplate
+	// flaky is set when the tests are run by --detect-flaky.
+	flaky *flakyRuns
+	// quarantine is the list of tests from --quarantine-file.
+	quarantine *quarantine.List
 }
 
 type writeSyncer interface {
@@ -36,7 +54,7 @@ func (h *eventHandler) Err(text string) error {
 }
 
 func (h *eventHandler) Event(event testjson.TestEvent, execution *testjson.Execution) error {
-	if err := writeWithNewline(h.jsonFile, event.Bytes()); err != nil {
+	if err := h.writeJSONFile(event); err != nil {
 		return fmt.Errorf("failed to write JSON file: %w", err)
 	}
 	if event.Action.IsTerminal() {
@@ -56,6 +74,49 @@ func (h *eventHandler) Event(event testjson.TestEvent, execution *testjson.Execu
 	return nil
 }
 
+// writeJSONFile writes event to the --jsonfile. The end event of a quarantined
+// test is preceded by attr events with the quarantine attributes, so that the
+// test is marked in the same way as a test that calls T.Attr.
+func (h *eventHandler) writeJSONFile(event testjson.TestEvent) error {
+	if h.jsonFile == nil || len(event.Bytes()) == 0 {
+		return nil
+	}
+	// Quarantine attributes read from an earlier --jsonfile are replaced by
+	// the attributes from the current quarantine file.
+	if event.Action == testjson.ActionAttr && strings.HasPrefix(event.Key, testjson.AttrQuarantined) {
+		return nil
+	}
+	if !event.PackageEvent() && event.Action.IsTerminal() {
+		for _, attr := range h.quarantine.Attrs(event.Package, testjson.TestName(event.Test)) {
+			raw, err := json.Marshal(attrEvent{
+				Time:    event.Time,
+				Action:  testjson.ActionAttr,
+				Package: event.Package,
+				Test:    event.Test,
+				Key:     attr.Key,
+				Value:   attr.Value,
+			})
+			if err != nil {
+				return err
+			}
+			if err := writeWithNewline(h.jsonFile, raw); err != nil {
+				return err
+			}
+		}
+	}
+	return writeWithNewline(h.jsonFile, event.Bytes())
+}
+
+// attrEvent is the go test -json format of an event for T.Attr.
+type attrEvent struct {
+	Time    time.Time `json:",omitzero"`
+	Action  testjson.Action
+	Package string
+	Test    string
+	Key     string
+	Value   string
+}
+
 func writeWithNewline(out io.Writer, b []byte) error {
 	// ignore artificial events that have len(b) == 0
 	if out == nil || len(b) == 0 {
@@ -81,7 +142,24 @@ func (h *eventHandler) Flush() {
 	}
 }
 
+// closeFormatter closes the formatter if it implements io.Closer. Some
+// formats can only write the end of their output once all the events have been
+// handled. The formatter is only closed once.
+func (h *eventHandler) closeFormatter() error {
+	closer, ok := h.formatter.(io.Closer)
+	if !ok || h.formatterClosed {
+		return nil
+	}
+	h.formatterClosed = true
+	return closer.Close()
+}
+
+// Close the formatter, if it was not already closed by finishRun, and the
+// JSON files.
 func (h *eventHandler) Close() error {
+	if err := h.closeFormatter(); err != nil {
+		log.Errorf("Failed to close formatter: %v", err)
+	}
 	if h.jsonFile != nil {
 		if err := h.jsonFile.Close(); err != nil {
 			log.Errorf("Failed to close JSON file: %v", err)
@@ -98,24 +176,36 @@ func (h *eventHandler) Close() error {
 var _ testjson.EventHandler = &eventHandler{}
 
 func newEventHandler(opts *options) (*eventHandler, error) {
-	formatter := testjson.NewEventFormatter(opts.stdout, opts.format, opts.formatOptions)
-	if formatter == nil {
-		return nil, fmt.Errorf("unknown format %s", opts.format)
-	}
+	var err error
 	handler := &eventHandler{
-		formatter: formatter,
-		err:       bufio.NewWriter(opts.stderr),
-		maxFails:  opts.maxFails,
+		err:      bufio.NewWriter(opts.stderr),
+		maxFails: opts.maxFails,
+	}
+	if opts.summaryTemplate != "" {
+		text, err := readTemplate(opts.summaryTemplate)
+		if err != nil {
+			return nil, err
+		}
+		handler.summary, err = testjson.NewSummaryTemplate(text, opts.formatOptions)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.quarantineFile != "" {
+		handler.quarantine, err = quarantine.Load(opts.quarantineFile, time.Now())
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	switch opts.format {
-	case "dots", "dots-v1", "dots-v2":
+	case "dots", "dots-v1", "dots-v2", "tui":
 		// Discard the error from the handler to prevent extra lines. The
 		// error will be printed in the summary.
 		handler.err = bufio.NewWriter(io.Discard)
 	}
 
-	var err error
 	if opts.jsonFile != "" {
 		_ = os.MkdirAll(filepath.Dir(opts.jsonFile), 0o755)
 		handler.jsonFile, err = os.Create(opts.jsonFile)
@@ -130,10 +220,64 @@ func newEventHandler(opts *options) (*eventHandler, error) {
 			return handler, fmt.Errorf("failed to create file: %w", err)
 		}
 	}
+
+	// The formatter is created last, because some formatters start a process
+	// or change the terminal, and must be closed once they are created.
+	formatter, err := newEventFormatter(opts)
+	if err != nil {
+		_ = handler.Close()
+		return nil, err
+	}
+	handler.formatter = formatter
 	return handler, nil
 }
 
-func writeJUnitFile(opts *options, execution *testjson.Execution) error {
+func newEventFormatter(opts *options) (testjson.EventFormatter, error) {
+	if command, ok := strings.CutPrefix(opts.format, "exec:"); ok {
+		return newFormatPlugin(command, opts.stdout, opts.stderr)
+	}
+	if opts.format == "template" {
+		if opts.formatTemplate == "" {
+			return nil, fmt.Errorf("--format-template is required with --format=template")
+		}
+		text, err := readTemplate(opts.formatTemplate)
+		if err != nil {
+			return nil, err
+		}
+		return testjson.NewTemplateFormatter(opts.stdout, text, opts.formatOptions)
+	}
+	formatter := testjson.NewEventFormatter(opts.stdout, opts.format, opts.formatOptions)
+	if formatter == nil {
+		return nil, fmt.Errorf("unknown format %s", opts.format)
+	}
+	return formatter, nil
+}
+
+// readTemplate returns the text of a template flag value. A value that starts
+// with @ is the name of a file that contains the template.
+func readTemplate(value string) (string, error) {
+	filename, ok := strings.CutPrefix(value, "@")
+	if !ok {
+		return value, nil
+	}
+	raw, err := os.ReadFile(filename)
+	if err != nil {
+		return "", fmt.Errorf("failed to read template: %w", err)
+	}
+	return string(raw), nil
+}
+
+// printSummary prints the summary of the execution using the summary template,
+// or with testjson.PrintSummary when there is no template.
+func (h *eventHandler) printSummary(out io.Writer, exec *testjson.Execution, opts testjson.Summary) error {
+	if h.summary == nil {
+		testjson.PrintSummary(out, exec, opts)
+		return nil
+	}
+	return h.summary.Print(out, exec)
+}
+
+func writeJUnitFile(opts *options, execution *testjson.Execution, locations *testlocation.Index) error {
 	if opts.junitFile == "" {
 		return nil
 	}
@@ -148,15 +292,150 @@ func writeJUnitFile(opts *options, execution *testjson.Execution) error {
 		}
 	}()
 
-	return junitxml.Write(junitFile, execution, junitxml.Config{
+	return junitxml.Write(junitFile, execution, junitConfig(opts, locations))
+}
+
+func writeJUnitDir(opts *options, execution *testjson.Execution, locations *testlocation.Index) error {
+	if opts.junitDir == "" {
+		return nil
+	}
+	cfg := junitConfig(opts, locations)
+	cfg.DirIndex = opts.junitDirIndex
+	return junitxml.WriteDir(opts.junitDir, execution, cfg)
+}
+
+func junitConfig(opts *options, locations *testlocation.Index) junitxml.Config {
+	// the dialect is validated by options.Validate
+	dialect, _ := junitxml.ParseDialect(opts.junitDialect)
+	if !opts.junitTestLocations {
+		locations = nil
+	}
+	return junitxml.Config{
+		Dialect:                 dialect,
+		TestLocations:           locations,
 		ProjectName:             opts.junitProjectName,
 		FormatTestSuiteName:     opts.junitTestSuiteNameFormat.Value(),
 		FormatTestCaseClassname: opts.junitTestCaseClassnameFormat.Value(),
 		HideEmptyPackages:       opts.junitHideEmptyPackages,
 		HideSkippedTests:        opts.junitHideSkippedTests,
+		IncludeOutput:           opts.junitIncludeOutput,
+		MaxOutputSize:           opts.junitMaxOutputSize,
+		CollapseReruns:          opts.junitCollapseReruns,
+	}
+}
+
+// loadTestLocations loads the location of tests once for all the reports that
+// use them. It returns nil if no report uses the locations. The locations are
+// optional in the JUnit file, so an error only logs a warning, but every test
+// in the sonar test report must belong to a file, so an error is returned
+// when the sonar test report is enabled.
+func loadTestLocations(opts *options, execution *testjson.Execution) (*testlocation.Index, error) {
+	junit := opts.junitTestLocations && (opts.junitFile != "" || opts.junitDir != "")
+	if !junit && opts.sonarTestReport == "" {
+		return nil, nil
+	}
+	locations, err := testlocation.Load(testPackages(execution))
+	switch {
+	case err == nil:
+		return locations, nil
+	case opts.sonarTestReport != "":
+		return nil, fmt.Errorf("failed to find the location of tests: %v", err)
+	default:
+		log.Warnf("Failed to find the location of tests for the JUnit file: %v", err)
+		return nil, nil
+	}
+}
+
+// testPackages returns the names of the packages in execution. The package
+// with an empty name, created by build-output events, is omitted because
+// loading it would load the package in the current directory.
+func testPackages(execution *testjson.Execution) []string {
+	var result []string
+	for _, name := range execution.Packages() {
+		if name != "" {
+			result = append(result, name)
+		}
+	}
+	return result
+}
+
+func writeHTMLFile(opts *options, execution *testjson.Execution) error {
+	if opts.htmlFile == "" {
+		return nil
+	}
+	return writeReportFile(opts.htmlFile, func(out io.Writer) error {
+		return htmlreport.Write(out, execution, htmlreport.Config{})
+	})
+}
+
+func writeCTRFFile(opts *options, execution *testjson.Execution) error {
+	if opts.ctrfFile == "" {
+		return nil
+	}
+	return writeReportFile(opts.ctrfFile, func(out io.Writer) error {
+		return ctrf.Write(out, execution, ctrf.Config{ToolVersion: version})
+	})
+}
+
+func writeXUnitFile(opts *options, execution *testjson.Execution) error {
+	if opts.xunitFile == "" {
+		return nil
+	}
+	return writeReportFile(opts.xunitFile, func(out io.Writer) error {
+		return xunitxml.Write(out, execution, xunitxml.Config{ToolVersion: version})
+	})
+}
+
+func writeNUnitFile(opts *options, execution *testjson.Execution) error {
+	if opts.nunitFile == "" {
+		return nil
+	}
+	return writeReportFile(opts.nunitFile, func(out io.Writer) error {
+		return nunitxml.Write(out, execution, nunitxml.Config{ToolVersion: version})
+	})
+}
+
+func writeSonarTestReport(opts *options, execution *testjson.Execution, locations *testlocation.Index) error {
+	if opts.sonarTestReport == "" {
+		return nil
+	}
+	return writeReportFile(opts.sonarTestReport, func(out io.Writer) error {
+		return sonarxml.Write(out, execution, sonarxml.Config{TestLocations: locations})
 	})
 }
 
+func writeAllureDir(opts *options, execution *testjson.Execution) error {
+	if opts.allureDir == "" {
+		return nil
+	}
+	return allure.WriteDir(opts.allureDir, execution, allure.Config{})
+}
+
+func writeTraceFile(opts *options, execution *testjson.Execution) error {
+	if opts.traceFile == "" {
+		return nil
+	}
+	return writeReportFile(opts.traceFile, func(out io.Writer) error {
+		return chrometrace.Write(out, execution)
+	})
+}
+
+// writeReportFile creates the file at path, and any missing parent
+// directories, and calls write to write the report to the file.
+func writeReportFile(path string, write func(io.Writer) error) error {
+	_ = os.MkdirAll(filepath.Dir(path), 0o755)
+	fh, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %v", err)
+	}
+	defer func() {
+		if err := fh.Close(); err != nil {
+			log.Errorf("Failed to close %v: %v", path, err)
+		}
+	}()
+	return write(fh)
+}
+
 func postRunHook(opts *options, execution *testjson.Execution) error {
 	command := opts.postRunHookCmd.Value()
 	if len(command) == 0 {
@@ -172,6 +451,7 @@ func postRunHook(opts *options, execution *testjson.Execution) error {
 		"GOTESTSUM_JSONFILE="+opts.jsonFile,
 		"GOTESTSUM_JSONFILE_TIMING_EVENTS="+opts.jsonFileTimingEvents,
 		"GOTESTSUM_JUNITFILE="+opts.junitFile,
+		"GOTESTSUM_JUNITFILE_DIR="+opts.junitDir,
 		fmt.Sprintf("GOTESTSUM_ELAPSED=%.3fs", execution.Elapsed().Seconds()),
 		fmt.Sprintf("TESTS_TOTAL=%d", execution.Total()),
 		fmt.Sprintf("TESTS_FAILED=%d", len(execution.Failed())),
@@ -15,6 +15,7 @@ import (
 	"gotest.tools/v3/assert/cmp"
 	"gotest.tools/v3/fs"
 	"gotest.tools/v3/golden"
+	"gotest.tools/v3/icmd"
 )
 
 func TestPostRunHook(t *testing.T) {
@@ -28,6 +29,7 @@ func TestPostRunHook(t *testing.T) {
 		jsonFile:             "events.json",
 		jsonFileTimingEvents: "timing.json",
 		junitFile:            "junit.xml",
+		junitDir:             "junit",
 		stdout:               buf,
 	}
 
@@ -73,6 +75,8 @@ func (bufferCloser) Sync() error { return nil }
 
 func TestEventHandler_Event_WithMissingActionFail(t *testing.T) {
 	t.Setenv("GITHUB_ACTIONS", "no")
+	t.Setenv("TF_BUILD", "")
+	t.Setenv("GITLAB_CI", "")
 
 	buf := new(bufferCloser)
 	errBuf := new(bytes.Buffer)
@@ -131,13 +135,185 @@ func TestWriteJunitFile_CreatesDirectory(t *testing.T) {
 		junitTestSuiteNameFormat:     &junitFieldFormatValue{},
 	}
 	exec := &testjson.Execution{}
-	err := writeJUnitFile(opts, exec)
+	err := writeJUnitFile(opts, exec, nil)
 	assert.NilError(t, err)
 
 	_, err = os.Stat(junitFile)
 	assert.NilError(t, err)
 }
 
+func TestWriteJUnitDir_CreatesDirectory(t *testing.T) {
+	dir := fs.NewDir(t, t.Name())
+	junitDir := filepath.Join(dir.Path(), "new-path", "junit")
+
+	opts := &options{
+		junitDir:                     junitDir,
+		junitDirIndex:                true,
+		junitTestCaseClassnameFormat: &junitFieldFormatValue{},
+		junitTestSuiteNameFormat:     &junitFieldFormatValue{},
+	}
+	exec := newExecFromTestData(t)
+	err := writeJUnitDir(opts, exec, nil)
+	assert.NilError(t, err)
+
+	_, err = os.Stat(filepath.Join(junitDir, "TEST-gotest.tools_gotestsum_testjson_internal_good.xml"))
+	assert.NilError(t, err)
+	_, err = os.Stat(filepath.Join(junitDir, "index.json"))
+	assert.NilError(t, err)
+}
+
+func TestWriteHTMLFile_CreatesDirectory(t *testing.T) {
+	dir := fs.NewDir(t, t.Name())
+	htmlFile := filepath.Join(dir.Path(), "new-path", "report.html")
+
+	opts := &options{htmlFile: htmlFile}
+	exec := newExecFromTestData(t)
+	err := writeHTMLFile(opts, exec)
+	assert.NilError(t, err)
+
+	_, err = os.Stat(htmlFile)
+	assert.NilError(t, err)
+}
+
+func TestNewEventHandler_FormatTemplate(t *testing.T) {
+	t.Run("missing template", func(t *testing.T) {
+		opts := &options{stdout: new(bytes.Buffer), format: "template"}
+		_, err := newEventHandler(opts)
+		assert.Error(t, err, "--format-template is required with --format=template")
+	})
+
+	t.Run("template from file", func(t *testing.T) {
+		dir := fs.NewDir(t, t.Name(),
+			fs.WithFile("format.tmpl", "{{ .Event.Action }} {{ .Event.Test }}\n"),
+			fs.WithFile("summary.tmpl", "{{ .Total }} tests\n"))
+		out := new(bytes.Buffer)
+		opts := &options{
+			stdout:          out,
+			format:          "template",
+			formatTemplate:  "@" + dir.Join("format.tmpl"),
+			summaryTemplate: "@" + dir.Join("summary.tmpl"),
+		}
+		handler, err := newEventHandler(opts)
+		assert.NilError(t, err)
+
+		exec, err := testjson.ScanTestOutput(testjson.ScanConfig{
+			Stdout:  strings.NewReader(`{"Action":"pass","Package":"pkg","Test":"TestOne"}`),
+			Handler: handler,
+		})
+		assert.NilError(t, err)
+		assert.NilError(t, handler.printSummary(out, exec, testjson.SummarizeAll))
+		assert.Equal(t, out.String(), "pass TestOne\n1 tests\n")
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		opts := &options{
+			stdout:         new(bytes.Buffer),
+			format:         "template",
+			formatTemplate: "@does-not-exist.tmpl",
+		}
+		_, err := newEventHandler(opts)
+		assert.ErrorContains(t, err, "failed to read template: ")
+	})
+}
+
+func TestWriteCTRFFile_CreatesDirectory(t *testing.T) {
+	dir := fs.NewDir(t, t.Name())
+	ctrfFile := filepath.Join(dir.Path(), "new-path", "ctrf-report.json")
+
+	opts := &options{ctrfFile: ctrfFile}
+	exec := newExecFromTestData(t)
+	err := writeCTRFFile(opts, exec)
+	assert.NilError(t, err)
+
+	_, err = os.Stat(ctrfFile)
+	assert.NilError(t, err)
+}
+
+func TestWriteXUnitFile_CreatesDirectory(t *testing.T) {
+	dir := fs.NewDir(t, t.Name())
+	xunitFile := filepath.Join(dir.Path(), "new-path", "xunit.xml")
+
+	opts := &options{xunitFile: xunitFile}
+	exec := newExecFromTestData(t)
+	err := writeXUnitFile(opts, exec)
+	assert.NilError(t, err)
+
+	_, err = os.Stat(xunitFile)
+	assert.NilError(t, err)
+}
+
+func TestWriteNUnitFile_CreatesDirectory(t *testing.T) {
+	dir := fs.NewDir(t, t.Name())
+	nunitFile := filepath.Join(dir.Path(), "new-path", "nunit.xml")
+
+	opts := &options{nunitFile: nunitFile}
+	exec := newExecFromTestData(t)
+	err := writeNUnitFile(opts, exec)
+	assert.NilError(t, err)
+
+	_, err = os.Stat(nunitFile)
+	assert.NilError(t, err)
+}
+
+func TestWriteSonarTestReport_CreatesDirectory(t *testing.T) {
+	dir := fs.NewDir(t, t.Name())
+	sonarFile := filepath.Join(dir.Path(), "new-path", "sonar.xml")
+
+	opts := &options{sonarTestReport: sonarFile}
+	exec := &testjson.Execution{}
+	err := writeSonarTestReport(opts, exec, nil)
+	assert.NilError(t, err)
+
+	_, err = os.Stat(sonarFile)
+	assert.NilError(t, err)
+}
+
+func TestLoadTestLocations_NotUsedByAnyReport(t *testing.T) {
+	// --junitfile-test-locations has no effect without a JUnit file
+	opts := &options{junitTestLocations: true}
+	exec := newExecFromTestData(t)
+	locations, err := loadTestLocations(opts, exec)
+	assert.NilError(t, err)
+	assert.Assert(t, locations == nil)
+}
+
+func TestTestPackages_OmitsBuildOutputPackage(t *testing.T) {
+	exec := newExecution(t, dedentOutput(`
+		{"ImportPath": "pkg [pkg.test]", "Action": "build-output", "Output": "# pkg\n"}
+		{"ImportPath": "pkg [pkg.test]", "Action": "build-fail"}
+		{"Package": "pkg", "Test": "TestOne", "Action": "run"}
+		{"Package": "pkg", "Test": "TestOne", "Action": "pass"}
+	`))
+	assert.DeepEqual(t, testPackages(exec), []string{"pkg"})
+}
+
+func TestWriteAllureDir_CreatesDirectory(t *testing.T) {
+	dir := fs.NewDir(t, t.Name())
+	allureDir := filepath.Join(dir.Path(), "new-path", "allure-results")
+
+	opts := &options{allureDir: allureDir}
+	exec := newExecFromTestData(t)
+	err := writeAllureDir(opts, exec)
+	assert.NilError(t, err)
+
+	entries, err := os.ReadDir(allureDir)
+	assert.NilError(t, err)
+	assert.Assert(t, len(entries) > 0)
+}
+
+func TestWriteTraceFile_CreatesDirectory(t *testing.T) {
+	dir := fs.NewDir(t, t.Name())
+	traceFile := filepath.Join(dir.Path(), "new-path", "trace.json")
+
+	opts := &options{traceFile: traceFile}
+	exec := newExecFromTestData(t)
+	err := writeTraceFile(opts, exec)
+	assert.NilError(t, err)
+
+	_, err = os.Stat(traceFile)
+	assert.NilError(t, err)
+}
+
 func TestScanTestOutput_TestTimeoutPanicRace(t *testing.T) {
 	run := func(t *testing.T, name string) {
 		format := testjson.NewEventFormatter(io.Discard, "testname", testjson.FormatOptions{})
@@ -179,6 +355,8 @@ func TestScanTestOutput_TestTimeoutPanicRace(t *testing.T) {
 // and no tests are run in the package.
 func TestEventHandler_TestBuildFail(t *testing.T) {
 	t.Setenv("GITHUB_ACTIONS", "no")
+	t.Setenv("TF_BUILD", "")
+	t.Setenv("GITLAB_CI", "")
 
 	buf := new(bufferCloser)
 	errBuf := new(bytes.Buffer)
@@ -203,6 +381,8 @@ func TestEventHandler_TestBuildFail(t *testing.T) {
 // due to syntax errors and the like.
 func TestEventHandler_SetupFail(t *testing.T) {
 	t.Setenv("GITHUB_ACTIONS", "no")
+	t.Setenv("TF_BUILD", "")
+	t.Setenv("GITLAB_CI", "")
 
 	buf := new(bufferCloser)
 	errBuf := new(bytes.Buffer)
@@ -222,3 +402,83 @@ func TestEventHandler_SetupFail(t *testing.T) {
 	actual := text.ProcessLines(t, out, text.OpRemoveSummaryLineElapsedTime)
 	golden.Assert(t, actual, "expected/setup-fail-expected")
 }
+
+func TestNewEventHandler_FormatPlugin(t *testing.T) {
+	plugin := buildFormatPlugin(t)
+
+	newOpts := func(mode string) (*options, *bytes.Buffer) {
+		out := new(bytes.Buffer)
+		return &options{
+			stdout: out,
+			stderr: new(bytes.Buffer),
+			format: "exec:" + plugin + " " + mode,
+		}, out
+	}
+
+	t.Run("events", func(t *testing.T) {
+		opts, out := newOpts("ok")
+		handler, err := newEventHandler(opts)
+		assert.NilError(t, err)
+
+		_, err = testjson.ScanTestOutput(testjson.ScanConfig{
+			Stdout:  readFile(t, "../testjson/testdata/input/go-test-json.out"),
+			Handler: handler,
+		})
+		assert.NilError(t, err)
+		assert.NilError(t, handler.closeFormatter())
+		golden.Assert(t, out.String(), "format-plugin-expected")
+	})
+
+	t.Run("bad handshake", func(t *testing.T) {
+		opts, _ := newOpts("no-handshake")
+		_, err := newEventHandler(opts)
+		assert.ErrorContains(t, err, `handshake failed: expected "gotestsum-format/1", got "something else"`)
+	})
+
+	t.Run("plugin crash", func(t *testing.T) {
+		opts, _ := newOpts("crash")
+		handler, err := newEventHandler(opts)
+		assert.NilError(t, err)
+
+		_, err = testjson.ScanTestOutput(testjson.ScanConfig{
+			Stdout:  readFile(t, "../testjson/testdata/input/go-test-json.out"),
+			Handler: handler,
+		})
+		// The events may all be written to the pipe buffer before the plugin
+		// exits, in which case the error is only returned by Close.
+		if err != nil {
+			assert.ErrorContains(t, err, "failed: exit status 3")
+		}
+		assert.ErrorContains(t, handler.closeFormatter(), "failed: exit status 3")
+	})
+
+	t.Run("closed by handler Close", func(t *testing.T) {
+		opts, _ := newOpts("ok")
+		handler, err := newEventHandler(opts)
+		assert.NilError(t, err)
+
+		// Close is deferred by run, so the plugin exits when run returns an
+		// error before finishRun.
+		assert.NilError(t, handler.Close())
+		plugin := handler.formatter.(*formatPlugin)
+		assert.Assert(t, plugin.exited)
+		assert.Equal(t, plugin.cmd.ProcessState.ExitCode(), 0)
+	})
+}
+
+// buildFormatPlugin builds the format plugin in testdata/formatplugin, and
+// returns the path to the binary.
+func buildFormatPlugin(t *testing.T) string {
+	t.Helper()
+	plugin := filepath.Join(t.TempDir(), "formatplugin")
+	icmd.RunCommand("go", "build", "-o", plugin, "./testdata/formatplugin").
+		Assert(t, icmd.Success)
+	return plugin
+}
+
+func readFile(t *testing.T, filename string) io.Reader {
+	t.Helper()
+	raw, err := os.ReadFile(filename)
+	assert.NilError(t, err)
+	return bytes.NewReader(raw)
+}
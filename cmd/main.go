@@ -15,7 +15,9 @@ import (
 
 	"github.com/dnephin/pflag"
 	"github.com/fatih/color"
+	"gotest.tools/gotestsum/internal/junitxml"
 	"gotest.tools/gotestsum/internal/log"
+	"gotest.tools/gotestsum/internal/quarantine"
 	"gotest.tools/gotestsum/testjson"
 )
 
@@ -76,6 +78,15 @@ func setupFlags(name string) (*pflag.FlagSet, *options) {
 	flags.StringVar(&opts.formatOptions.Icons, "format-icons",
 		lookEnvWithDefault("GOTESTSUM_FORMAT_ICONS", ""),
 		"use different icons, see help for options")
+	flags.BoolVar(&opts.formatOptions.GitHubStepSummary, "github-step-summary",
+		truthyFlag(lookEnvWithDefault("GOTESTSUM_GITHUB_STEP_SUMMARY", "")),
+		"append a summary of the run to $GITHUB_STEP_SUMMARY with the github-actions format")
+	flags.StringVar(&opts.formatTemplate, "format-template",
+		lookEnvWithDefault("GOTESTSUM_FORMAT_TEMPLATE", ""),
+		"text/template used to print each event with --format=template, or @file to read it from a file")
+	flags.StringVar(&opts.summaryTemplate, "summary-template",
+		lookEnvWithDefault("GOTESTSUM_SUMMARY_TEMPLATE", ""),
+		"text/template used to print the summary, or @file to read it from a file")
 	flags.BoolVar(&opts.rawCommand, "raw-command", false,
 		"don't prepend 'go test -json' to the 'go test' command")
 	flags.BoolVar(&opts.ignoreNonJSONOutputLines, "ignore-non-json-output-lines", false,
@@ -121,6 +132,49 @@ func setupFlags(name string) (*pflag.FlagSet, *options) {
 	flags.BoolVar(&opts.junitHideSkippedTests, "junitfile-hide-skipped-tests",
 		truthyFlag(lookEnvWithDefault("GOTESTSUM_JUNIT_HIDE_SKIPPED_TESTS", "")),
 		"omit skipped tests from the junit.xml file")
+	flags.BoolVar(&opts.junitIncludeOutput, "junitfile-include-output",
+		truthyFlag(lookEnvWithDefault("GOTESTSUM_JUNITFILE_INCLUDE_OUTPUT", "")),
+		"include the output of every test in system-out, and package output in system-err")
+	flags.IntVar(&opts.junitMaxOutputSize, "junitfile-max-output-size",
+		intFlag("GOTESTSUM_JUNITFILE_MAX_OUTPUT_SIZE"),
+		"truncate system-out and system-err in the junit.xml file to this many bytes")
+	flags.BoolVar(&opts.junitCollapseReruns, "junitfile-collapse-reruns",
+		truthyFlag(lookEnvWithDefault("GOTESTSUM_JUNITFILE_COLLAPSE_RERUNS", "")),
+		"combine reruns of a test into one testcase with flakyFailure and rerunFailure elements")
+	flags.BoolVar(&opts.junitTestLocations, "junitfile-test-locations",
+		truthyFlag(lookEnvWithDefault("GOTESTSUM_JUNITFILE_TEST_LOCATIONS", "")),
+		"add the file and line of each test to the junit.xml file by loading the test packages")
+	flags.StringVar(&opts.junitDialect, "junitfile-dialect",
+		lookEnvWithDefault("GOTESTSUM_JUNITFILE_DIALECT", ""),
+		"adjust the junit.xml file for a CI system, one of: default, jenkins, gitlab, azure, ant. Skipped tests are omitted by ant")
+	flags.StringVar(&opts.junitDir, "junitfile-dir",
+		lookEnvWithDefault("GOTESTSUM_JUNITFILE_DIR", ""),
+		"write a JUnit XML file for each package to this directory")
+	flags.BoolVar(&opts.junitDirIndex, "junitfile-dir-index",
+		truthyFlag(lookEnvWithDefault("GOTESTSUM_JUNITFILE_DIR_INDEX", "")),
+		"write an index.json of the files in the --junitfile-dir directory")
+
+	flags.StringVar(&opts.htmlFile, "htmlfile",
+		lookEnvWithDefault("GOTESTSUM_HTMLFILE", ""),
+		"write a self-contained HTML report file")
+	flags.StringVar(&opts.ctrfFile, "ctrf-file",
+		lookEnvWithDefault("GOTESTSUM_CTRF_FILE", ""),
+		"write a CTRF JSON report file")
+	flags.StringVar(&opts.traceFile, "tracefile",
+		lookEnvWithDefault("GOTESTSUM_TRACEFILE", ""),
+		"write a Chrome Trace Event file with a timeline of the test run")
+	flags.StringVar(&opts.xunitFile, "xunitfile",
+		lookEnvWithDefault("GOTESTSUM_XUNITFILE", ""),
+		"write an xUnit.net v2 XML file")
+	flags.StringVar(&opts.nunitFile, "nunitfile",
+		lookEnvWithDefault("GOTESTSUM_NUNITFILE", ""),
+		"write an NUnit 3 XML file")
+	flags.StringVar(&opts.sonarTestReport, "sonar-test-report",
+		lookEnvWithDefault("GOTESTSUM_SONAR_TEST_REPORT", ""),
+		"write a SonarQube generic test execution report, grouped by the test file of each test")
+	flags.StringVar(&opts.allureDir, "allure-dir",
+		lookEnvWithDefault("GOTESTSUM_ALLURE_DIR", ""),
+		"write Allure result files for each test to this directory")
 
 	flags.IntVar(&opts.rerunFailsMaxAttempts, "rerun-fails", 0,
 		"rerun failed tests until they all pass, or attempts exceeds maximum. Defaults to max 2 reruns when enabled")
@@ -135,6 +189,17 @@ func setupFlags(name string) (*pflag.FlagSet, *options) {
 		"write a report to the file, of the tests that were rerun")
 	flags.BoolVar(&opts.rerunFailsRunRootCases, "rerun-fails-run-root-test", false,
 		"rerun the entire root testcase when any of its subtests fail, instead of only the failed subtest")
+	flags.BoolVar(&opts.rerunFailsBatch, "rerun-fails-batch", false,
+		"rerun the failed tests of each package with a single go test command, instead of one command for each test")
+	flags.IntVar(&opts.rerunFailsParallel, "rerun-fails-parallel", 1,
+		"rerun the failed tests of up to this number of packages at the same time")
+	flags.StringVar(&opts.rerunFailsFrom, "rerun-fails-from", "",
+		"rerun the failed tests from a --jsonfile of an earlier run, instead of running all the tests")
+	flags.StringVar(&opts.quarantineFile, "quarantine-file",
+		lookEnvWithDefault("GOTESTSUM_QUARANTINE_FILE", ""),
+		"YAML or JSON file of known flaky tests, whose failures do not change the exit code")
+	flags.IntVar(&opts.detectFlaky, "detect-flaky", 0,
+		"run the tests this number of times, and report the tests which passed in some runs and failed in others")
 
 	flags.BoolVar(&opts.debug, "debug", false, "enabled debug logging")
 	flags.BoolVar(&opts.version, "version", false, "show version and exit")
@@ -156,11 +221,18 @@ Flags:
 Formats:
     dots                     print a character for each test
     dots-v2                  experimental dots format, one package per line
+    tui                      interactive view of running packages, tests, and failures
     pkgname                  print a line for each package
     pkgname-and-test-fails   print a line for each package and failed test output
     testname                 print a line for each test and package
     testdox                  print a sentence for each test using gotestdox
     github-actions           testname format with github actions log grouping
+    azure-pipelines          testname format with azure pipelines log grouping
+    gitlab-ci                testname format with gitlab ci collapsible sections
+    tap                      Test Anything Protocol version 14
+    teamcity                 TeamCity service messages for each test
+    template                 print each event using the --format-template
+    exec:COMMAND             send each event to a format plugin COMMAND
     standard-quiet           standard go test format
     standard-verbose         standard go test -v format
 
@@ -189,6 +261,8 @@ type options struct {
 	args                         []string
 	format                       string
 	formatOptions                testjson.FormatOptions
+	formatTemplate               string
+	summaryTemplate              string
 	debug                        bool
 	rawCommand                   bool
 	ignoreNonJSONOutputLines     bool
@@ -203,11 +277,30 @@ type options struct {
 	junitProjectName             string
 	junitHideEmptyPackages       bool
 	junitHideSkippedTests        bool
+	junitIncludeOutput           bool
+	junitMaxOutputSize           int
+	junitCollapseReruns          bool
+	junitTestLocations           bool
+	junitDialect                 string
+	junitDir                     string
+	junitDirIndex                bool
+	htmlFile                     string
+	ctrfFile                     string
+	traceFile                    string
+	xunitFile                    string
+	nunitFile                    string
+	sonarTestReport              string
+	allureDir                    string
 	rerunFailsMaxAttempts        int
 	rerunFailsMaxInitialFailures int
 	rerunFailsReportFile         string
 	rerunFailsRunRootCases       bool
 	rerunFailsAbortOnDataRace    bool
+	rerunFailsBatch              bool
+	rerunFailsParallel           int
+	rerunFailsFrom               string
+	detectFlaky                  int
+	quarantineFile               string
 	packages                     []string
 	watch                        bool
 	watchClear                   bool
@@ -232,9 +325,35 @@ func (o options) Validate() error {
 		return fmt.Errorf("-(test.)failfast can not be used with --rerun-fails " +
 			"because not all test cases will run")
 	}
+	if o.detectFlaky > 0 && o.rerunFailsMaxAttempts > 0 {
+		return fmt.Errorf("--detect-flaky can not be used with --rerun-fails or --rerun-fails-from")
+	}
+	if _, err := junitxml.ParseDialect(o.junitDialect); err != nil {
+		return err
+	}
+	if o.watch && o.format == "tui" {
+		return fmt.Errorf("--format=tui can not be used with --watch")
+	}
+	// The summary printed before each rerun, or each run of --detect-flaky,
+	// would be drawn over by the tui format.
+	if o.format == "tui" && (o.rerunFailsMaxAttempts > 0 || o.rerunFailsFrom != "") {
+		return fmt.Errorf("--format=tui can not be used with --rerun-fails or --rerun-fails-from")
+	}
+	if o.format == "tui" && o.detectFlaky > 0 {
+		return fmt.Errorf("--format=tui can not be used with --detect-flaky")
+	}
 	return nil
 }
 
+// keepPassedOutput returns true if the output of passed tests is used by one
+// of the reports.
+func (o options) keepPassedOutput() bool {
+	if o.htmlFile != "" {
+		return true
+	}
+	return (o.junitFile != "" || o.junitDir != "") && o.junitIncludeOutput
+}
+
 func defaultNoColor() bool {
 	// fatih/color will only output color when stdout is a terminal which is not
 	// true for many CI environments which support color output. So instead, we
@@ -280,71 +399,165 @@ func run(opts *options) error {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	if opts.rerunFailsFrom != "" && opts.rerunFailsMaxAttempts == 0 {
+		opts.rerunFailsMaxAttempts = 2
+	}
 	if err := opts.Validate(); err != nil {
 		return err
 	}
+	if opts.rerunFailsFrom != "" {
+		return rerunFailsFromFile(ctx, opts)
+	}
+	if opts.detectFlaky > 0 {
+		return runDetectFlaky(ctx, opts)
+	}
 
-	goTestProc, err := startGoTestFn(ctx, "", goTestCmdArgs(opts, rerunOpts{}))
+	// The handler is created first, and closed on every return, because the
+	// formatter may need to reset the terminal, or stop a plugin process.
+	handler, err := newEventHandler(opts)
 	if err != nil {
 		return err
 	}
+	defer handler.Close() //nolint:errcheck
 
-	handler, err := newEventHandler(opts)
+	goTestProc, err := startGoTestFn(ctx, "", goTestStdin(opts), goTestCmdArgs(opts, rerunOpts{}))
 	if err != nil {
 		return err
 	}
-	defer handler.Close() //nolint:errcheck
+
 	cfg := testjson.ScanConfig{
 		Stdout:                   goTestProc.stdout,
 		Stderr:                   goTestProc.stderr,
 		Handler:                  handler,
 		Stop:                     cancel,
 		IgnoreNonJSONOutputLines: opts.ignoreNonJSONOutputLines,
+		KeepPassedOutput:         opts.keepPassedOutput(),
 	}
 	exec, err := testjson.ScanTestOutput(cfg)
 	handler.Flush()
 	if err != nil {
-		return finishRun(opts, exec, err)
+		return finishRun(opts, handler, exec, err)
 	}
 
 	exitErr := goTestProc.cmd.Wait()
 	if signum := atomic.LoadInt32(&goTestProc.signal); signum != 0 {
-		return finishRun(opts, exec, exitError{num: signalExitCode + int(signum)})
+		return finishRun(opts, handler, exec, exitError{num: signalExitCode + int(signum)})
 	}
 	if exitErr == nil || opts.rerunFailsMaxAttempts == 0 {
-		return finishRun(opts, exec, exitErr)
+		return finishRun(opts, handler, exec, exitErr)
 	}
+	return rerunAndFinishRun(ctx, opts, handler, exec, exitErr, exec.Failed())
+}
+
+// rerunAndFinishRun reruns failures, unless exec has errors or too many
+// failures, and then calls finishRun. exitErr is the error from the go test
+// command that produced exec. Quarantined tests are not rerun, and do not count
+// towards the maximum number of failures.
+func rerunAndFinishRun(
+	ctx context.Context,
+	opts *options,
+	handler *eventHandler,
+	exec *testjson.Execution,
+	exitErr error,
+	failures []testjson.TestCase,
+) error {
 	if err := hasErrors(exitErr, exec, opts); err != nil {
-		return finishRun(opts, exec, err)
+		return finishRun(opts, handler, exec, err)
 	}
 
-	failed := len(rerunFailsFilter(opts)(exec.Failed()))
+	tcFilter := rerunFailsFilter(opts, handler.quarantine)
+	failed := len(tcFilter(failures))
+	if failed == 0 {
+		return finishRun(opts, handler, exec, exitErr)
+	}
 	if failed > opts.rerunFailsMaxInitialFailures {
 		err := fmt.Errorf(
 			"number of test failures (%d) exceeds maximum (%d) set by --rerun-fails-max-failures",
 			failed, opts.rerunFailsMaxInitialFailures)
-		return finishRun(opts, exec, err)
+		return finishRun(opts, handler, exec, err)
 	}
 
-	cfg = testjson.ScanConfig{Execution: exec, Handler: handler}
-	exitErr = rerunFailed(ctx, opts, cfg)
+	cfg := testjson.ScanConfig{Execution: exec, Handler: handler}
+	exitErr = rerunFailures(ctx, opts, cfg, tcFilter, failures)
 	handler.Flush()
 	if err := writeRerunFailsReport(opts, exec); err != nil {
 		return err
 	}
-	return finishRun(opts, exec, exitErr)
+	return finishRun(opts, handler, exec, exitErr)
 }
 
-func finishRun(opts *options, exec *testjson.Execution, exitErr error) error {
-	testjson.PrintSummary(opts.stdout, exec, opts.hideSummary.value)
+func finishRun(opts *options, handler *eventHandler, exec *testjson.Execution, exitErr error) error {
+	handler.quarantine.Apply(exec)
+	if err := handler.closeFormatter(); err != nil {
+		return fmt.Errorf("failed to format events: %w", err)
+	}
+	if err := handler.printSummary(opts.stdout, exec, opts.hideSummary.value); err != nil {
+		return fmt.Errorf("failed to print summary: %w", err)
+	}
+	handler.flaky.printReport(opts.stdout, exec)
 
-	if err := writeJUnitFile(opts, exec); err != nil {
+	locations, err := loadTestLocations(opts, exec)
+	if err != nil {
+		return err
+	}
+	if err := writeJUnitFile(opts, exec, locations); err != nil {
 		return fmt.Errorf("failed to write junit file: %w", err)
 	}
+	if err := writeJUnitDir(opts, exec, locations); err != nil {
+		return fmt.Errorf("failed to write junit directory: %w", err)
+	}
+	if err := writeHTMLFile(opts, exec); err != nil {
+		return fmt.Errorf("failed to write html file: %w", err)
+	}
+	if err := writeCTRFFile(opts, exec); err != nil {
+		return fmt.Errorf("failed to write ctrf file: %w", err)
+	}
+	if err := writeTraceFile(opts, exec); err != nil {
+		return fmt.Errorf("failed to write trace file: %w", err)
+	}
+	if err := writeXUnitFile(opts, exec); err != nil {
+		return fmt.Errorf("failed to write xunit file: %w", err)
+	}
+	if err := writeNUnitFile(opts, exec); err != nil {
+		return fmt.Errorf("failed to write nunit file: %w", err)
+	}
+	if err := writeSonarTestReport(opts, exec, locations); err != nil {
+		return fmt.Errorf("failed to write sonar test report: %w", err)
+	}
+	if err := writeAllureDir(opts, exec); err != nil {
+		return fmt.Errorf("failed to write allure results: %w", err)
+	}
 	if err := postRunHook(opts, exec); err != nil {
 		return fmt.Errorf("post run command failed: %w", err)
 	}
-	return exitErr
+	return ignoreQuarantinedFailures(handler.quarantine, exec, exitErr)
+}
+
+// ignoreQuarantinedFailures returns nil if exitErr is the exit code of go test
+// and all the tests that failed are quarantined. Otherwise it returns exitErr.
+func ignoreQuarantinedFailures(list *quarantine.List, exec *testjson.Execution, exitErr error) error {
+	if list == nil || !IsExitCoder(exitErr) || ExitCodeWithDefault(exitErr) != 1 {
+		return exitErr
+	}
+	if len(exec.Errors()) > 0 {
+		return exitErr
+	}
+	for _, name := range exec.Packages() {
+		if exec.Package(name).TestMainFailed() {
+			return exitErr
+		}
+	}
+
+	failures := testjson.FilterFailedUnique(lastAttemptFailed(exec))
+	if len(failures) == 0 {
+		return exitErr
+	}
+	for _, tc := range failures {
+		if _, ok := list.Match(tc.Package, tc.Test); !ok {
+			return exitErr
+		}
+	}
+	return nil
 }
 
 func goTestCmdArgs(opts *options, rerunOpts rerunOpts) []string {
@@ -443,17 +656,26 @@ type proc struct {
 	signal int32
 }
 
+// goTestStdin returns the stdin for the go test command. The tui format reads
+// key presses from stdin, so go test must not read from it as well.
+func goTestStdin(opts *options) io.Reader {
+	if opts.format == "tui" {
+		return nil
+	}
+	return os.Stdin
+}
+
 type waiter interface {
 	Wait() error
 }
 
-func startGoTest(ctx context.Context, dir string, args []string) (*proc, error) {
+func startGoTest(ctx context.Context, dir string, stdin io.Reader, args []string) (*proc, error) {
 	if len(args) == 0 {
 		return nil, errors.New("missing command to run")
 	}
 
 	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
-	cmd.Stdin = os.Stdin
+	cmd.Stdin = stdin
 	cmd.Dir = dir
 
 	p := proc{cmd: cmd}
@@ -31,6 +31,8 @@ func TestE2E_RerunFails(t *testing.T) {
 		t.Skip("too slow for short run")
 	}
 	t.Setenv("GITHUB_ACTIONS", "no")
+	t.Setenv("TF_BUILD", "")
+	t.Setenv("GITLAB_CI", "")
 
 	type testCase struct {
 		name        string
@@ -87,6 +89,17 @@ func TestE2E_RerunFails(t *testing.T) {
 			},
 			expectedErr: "exit status 1",
 		},
+		{
+			name: "reruns each package with one command",
+			args: []string{
+				"-f=testname",
+				"--rerun-fails=2",
+				"--rerun-fails-batch",
+				"--packages=./testdata/e2e/flaky/",
+				"--", "-count=1", "-tags=testdata",
+			},
+			expectedErr: "exit status 1",
+		},
 		{
 			name: "first run has errors, abort rerun",
 			args: []string{
@@ -229,6 +242,8 @@ func TestE2E_IgnoresWarnings(t *testing.T) {
 		t.Skip("too slow for short run")
 	}
 	t.Setenv("GITHUB_ACTIONS", "no")
+	t.Setenv("TF_BUILD", "")
+	t.Setenv("GITLAB_CI", "")
 
 	flags, opts := setupFlags("gotestsum")
 	args := []string{
@@ -15,6 +15,7 @@ import (
 	"gotest.tools/v3/assert"
 	"gotest.tools/v3/assert/cmp"
 	"gotest.tools/v3/env"
+	"gotest.tools/v3/fs"
 	"gotest.tools/v3/golden"
 	"gotest.tools/v3/skip"
 )
@@ -93,6 +94,25 @@ func TestOptions_Validate_FromFlags(t *testing.T) {
 			args:     []string{"--rerun-fails", "--packages=./...", "--", "-test.failfast"},
 			expected: "-(test.)failfast can not be used with --rerun-fails",
 		},
+		{
+			name: "junitfile-dialect",
+			args: []string{"--junitfile-dialect=jenkins"},
+		},
+		{
+			name:     "tui format with rerun-fails",
+			args:     []string{"--format=tui", "--rerun-fails"},
+			expected: "--format=tui can not be used with --rerun-fails",
+		},
+		{
+			name:     "tui format with detect-flaky",
+			args:     []string{"--format=tui", "--detect-flaky=3"},
+			expected: "--format=tui can not be used with --detect-flaky",
+		},
+		{
+			name:     "junitfile-dialect, invalid",
+			args:     []string{"--junitfile-dialect=bogus"},
+			expected: `invalid JUnit dialect "bogus"`,
+		},
 	}
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
@@ -101,6 +121,13 @@ func TestOptions_Validate_FromFlags(t *testing.T) {
 	}
 }
 
+func TestOptions_KeepPassedOutput(t *testing.T) {
+	assert.Assert(t, !options{}.keepPassedOutput())
+	assert.Assert(t, !options{junitFile: "junit.xml"}.keepPassedOutput())
+	assert.Assert(t, options{junitFile: "junit.xml", junitIncludeOutput: true}.keepPassedOutput())
+	assert.Assert(t, options{htmlFile: "report.html"}.keepPassedOutput())
+}
+
 func TestGoTestCmdArgs(t *testing.T) {
 	type testCase struct {
 		opts      *options
@@ -441,6 +468,380 @@ func TestRun_RerunFails_PanicPreventsRerun(t *testing.T) {
 	assert.ErrorContains(t, err, "rerun aborted because previous run had a suspected panic", out.String())
 }
 
+func TestRun_RerunFailsFrom(t *testing.T) {
+	prev := `{"Package": "pkg", "Action": "run"}
+{"Package": "pkg", "Test": "TestOne", "Action": "run"}
+{"Package": "pkg", "Test": "TestOne", "Action": "fail"}
+{"Package": "pkg", "Test": "TestTwo", "Action": "run"}
+{"Package": "pkg", "Test": "TestTwo", "Action": "fail"}
+{"Package": "pkg", "Test": "TestThree", "Action": "run"}
+{"Package": "pkg", "Test": "TestThree", "Action": "pass"}
+{"Package": "pkg", "Action": "fail"}
+{"Package": "pkg", "Test": "TestTwo", "Action": "run"}
+{"Package": "pkg", "Test": "TestTwo", "Action": "pass"}
+{"Package": "pkg", "Action": "pass"}
+`
+	jsonFile := fs.NewFile(t, "prev.json", fs.WithContent(prev))
+	rerunFailsReport := fs.NewFile(t, "rerun-report")
+
+	rerun := `{"Package": "pkg", "Action": "run"}
+{"Package": "pkg", "Test": "TestOne", "Action": "run"}
+{"Package": "pkg", "Test": "TestOne", "Action": "pass"}
+{"Package": "pkg", "Action": "pass"}
+`
+	var runs [][]string
+	fn := func(args []string) *proc {
+		runs = append(runs, args)
+		return &proc{
+			cmd:    fakeWaiter{},
+			stdout: strings.NewReader(rerun),
+			stderr: bytes.NewReader(nil),
+		}
+	}
+	reset := patchStartGoTestFn(fn)
+	defer reset()
+
+	out := new(bytes.Buffer)
+	opts := &options{
+		rawCommand:                   true,
+		args:                         []string{"./test.test"},
+		format:                       "testname",
+		rerunFailsFrom:               jsonFile.Path(),
+		rerunFailsMaxInitialFailures: 10,
+		rerunFailsReportFile:         rerunFailsReport.Path(),
+		jsonFile:                     jsonFile.Path(),
+		stdout:                       out,
+		stderr:                       os.Stderr,
+		hideSummary:                  newHideSummaryValue(),
+	}
+	err := run(opts)
+	assert.NilError(t, err, out.String())
+	assert.DeepEqual(t, runs, [][]string{{"./test.test", "-test.run=^TestOne$", "pkg"}})
+
+	raw, err := os.ReadFile(rerunFailsReport.Path())
+	assert.NilError(t, err)
+	assert.Equal(t, string(raw), "pkg.TestOne: 2 runs, 1 failures\npkg.TestTwo: 2 runs, 1 failures\n")
+
+	// The --jsonfile contains the events of the earlier run and the rerun.
+	raw, err = os.ReadFile(jsonFile.Path())
+	assert.NilError(t, err)
+	assert.Equal(t, string(raw), prev+rerun)
+}
+
+func TestRun_RerunFailsFrom_BuildFailed(t *testing.T) {
+	prev := `{"ImportPath": "pkg [pkg.test]", "Action": "build-output", "Output": "pkg/one_test.go:3:1: syntax error\n"}
+{"ImportPath": "pkg [pkg.test]", "Action": "build-fail"}
+{"Package": "pkg", "Action": "start"}
+{"Package": "pkg", "Action": "output", "Output": "FAIL\tpkg [build failed]\n", "FailedBuild": "pkg [pkg.test]"}
+{"Package": "pkg", "Action": "fail", "FailedBuild": "pkg [pkg.test]"}
+`
+	jsonFile := fs.NewFile(t, "prev.json", fs.WithContent(prev))
+
+	var runs [][]string
+	reset := patchStartGoTestFn(func(args []string) *proc {
+		runs = append(runs, args)
+		return &proc{cmd: fakeWaiter{}, stdout: strings.NewReader(""), stderr: bytes.NewReader(nil)}
+	})
+	defer reset()
+
+	out := new(bytes.Buffer)
+	opts := &options{
+		rawCommand:                   true,
+		args:                         []string{"./test.test"},
+		format:                       "testname",
+		rerunFailsFrom:               jsonFile.Path(),
+		rerunFailsMaxInitialFailures: 10,
+		stdout:                       out,
+		stderr:                       os.Stderr,
+		hideSummary:                  newHideSummaryValue(),
+	}
+	err := run(opts)
+	assert.ErrorContains(t, err, "rerun aborted because previous run had errors", out.String())
+	assert.Equal(t, len(runs), 0)
+}
+
+func TestRun_QuarantineFile(t *testing.T) {
+	quarantineFile := fs.NewFile(t, "quarantine.yaml", fs.WithContent(`
+tests:
+  - package: pkg
+    test: TestFlaky
+    reason: fails when the disk is slow
+`))
+
+	type testCase struct {
+		output      string
+		expectedErr string
+	}
+	fn := func(t *testing.T, tc testCase) {
+		reset := patchStartGoTestFn(func([]string) *proc {
+			return &proc{
+				cmd:    fakeWaiter{result: newExitCode("failed", 1)},
+				stdout: strings.NewReader(tc.output),
+				stderr: bytes.NewReader(nil),
+			}
+		})
+		defer reset()
+
+		junitFile := fs.NewFile(t, "junit.xml")
+		jsonFile := fs.NewFile(t, "test.json")
+		out := new(bytes.Buffer)
+		opts := &options{
+			rawCommand:     true,
+			args:           []string{"./test.test"},
+			format:         "testname",
+			quarantineFile: quarantineFile.Path(),
+			junitFile:      junitFile.Path(),
+			jsonFile:       jsonFile.Path(),
+			stdout:         out,
+			stderr:         os.Stderr,
+			hideSummary:    newHideSummaryValue(),
+		}
+		err := run(opts)
+		if tc.expectedErr != "" {
+			assert.Error(t, err, tc.expectedErr)
+		} else {
+			assert.NilError(t, err)
+		}
+		assert.Assert(t, cmp.Contains(out.String(), "=== QUARANTINED: pkg TestFlaky/sub"))
+
+		raw, err := os.ReadFile(junitFile.Path())
+		assert.NilError(t, err)
+		assert.Assert(t, cmp.Contains(string(raw),
+			`<property name="gotestsum.quarantined" value="fails when the disk is slow"></property>`))
+
+		raw, err = os.ReadFile(jsonFile.Path())
+		assert.NilError(t, err)
+		assert.Assert(t, cmp.Contains(string(raw), `{"Action":"attr","Package":"pkg","Test":"TestFlaky/sub",`+
+			`"Key":"gotestsum.quarantined","Value":"fails when the disk is slow"}
+{"Package": "pkg", "Test": "TestFlaky/sub", "Action": "fail"}`))
+	}
+
+	runCase(t, "only quarantined tests failed", func(t *testing.T) {
+		fn(t, testCase{
+			output: `{"Package": "pkg", "Test": "TestFlaky", "Action": "run"}
+{"Package": "pkg", "Test": "TestFlaky/sub", "Action": "run"}
+{"Package": "pkg", "Test": "TestFlaky/sub", "Action": "fail"}
+{"Package": "pkg", "Test": "TestFlaky", "Action": "fail"}
+{"Package": "pkg", "Test": "TestOne", "Action": "run"}
+{"Package": "pkg", "Test": "TestOne", "Action": "pass"}
+{"Package": "pkg", "Action": "fail"}
+`,
+		})
+	})
+	runCase(t, "other tests failed", func(t *testing.T) {
+		fn(t, testCase{
+			output: `{"Package": "pkg", "Test": "TestFlaky", "Action": "run"}
+{"Package": "pkg", "Test": "TestFlaky/sub", "Action": "run"}
+{"Package": "pkg", "Test": "TestFlaky/sub", "Action": "fail"}
+{"Package": "pkg", "Test": "TestFlaky", "Action": "fail"}
+{"Package": "pkg", "Test": "TestOne", "Action": "run"}
+{"Package": "pkg", "Test": "TestOne", "Action": "fail"}
+{"Package": "pkg", "Action": "fail"}
+`,
+			expectedErr: "failed",
+		})
+	})
+}
+
+func TestRun_QuarantineFile_QuarantinedTestsAreNotRerun(t *testing.T) {
+	quarantineFile := fs.NewFile(t, "quarantine.yaml", fs.WithContent(`
+tests:
+  - package: pkg
+    test: TestFlaky.*
+`))
+
+	outputs := []string{
+		`{"Package": "pkg", "Test": "TestFlakyOne", "Action": "run"}
+{"Package": "pkg", "Test": "TestFlakyOne", "Action": "fail"}
+{"Package": "pkg", "Test": "TestFlakyTwo", "Action": "run"}
+{"Package": "pkg", "Test": "TestFlakyTwo", "Action": "fail"}
+{"Package": "pkg", "Test": "TestOne", "Action": "run"}
+{"Package": "pkg", "Test": "TestOne", "Action": "fail"}
+{"Package": "pkg", "Action": "fail"}
+`,
+		`{"Package": "pkg", "Test": "TestOne", "Action": "run"}
+{"Package": "pkg", "Test": "TestOne", "Action": "pass"}
+{"Package": "pkg", "Action": "pass"}
+`,
+	}
+	var runs [][]string
+	reset := patchStartGoTestFn(func(args []string) *proc {
+		var result error
+		if len(runs) == 0 {
+			result = newExitCode("failed", 1)
+		}
+		out := outputs[len(runs)]
+		runs = append(runs, args)
+		return &proc{
+			cmd:    fakeWaiter{result: result},
+			stdout: strings.NewReader(out),
+			stderr: bytes.NewReader(nil),
+		}
+	})
+	defer reset()
+
+	opts := &options{
+		rawCommand:                   true,
+		args:                         []string{"./test.test"},
+		format:                       "testname",
+		quarantineFile:               quarantineFile.Path(),
+		rerunFailsMaxAttempts:        2,
+		rerunFailsMaxInitialFailures: 1,
+		stdout:                       new(bytes.Buffer),
+		stderr:                       os.Stderr,
+		hideSummary:                  newHideSummaryValue(),
+	}
+	assert.NilError(t, run(opts))
+	expected := [][]string{
+		{"./test.test"},
+		{"./test.test", "-test.run=^TestOne$", "pkg"},
+	}
+	assert.DeepEqual(t, runs, expected)
+}
+
+func TestRun_QuarantineFile_QuarantinedSubtestIsNotRerun(t *testing.T) {
+	quarantineFile := fs.NewFile(t, "quarantine.yaml", fs.WithContent(`
+tests:
+  - package: pkg
+    test: TestA/sub
+`))
+
+	outputs := []string{
+		`{"Package": "pkg", "Test": "TestA", "Action": "run"}
+{"Package": "pkg", "Test": "TestA/sub", "Action": "run"}
+{"Package": "pkg", "Test": "TestA/sub", "Action": "fail"}
+{"Package": "pkg", "Test": "TestA/other", "Action": "run"}
+{"Package": "pkg", "Test": "TestA/other", "Action": "pass"}
+{"Package": "pkg", "Test": "TestA", "Action": "fail"}
+{"Package": "pkg", "Test": "TestB", "Action": "run"}
+{"Package": "pkg", "Test": "TestB", "Action": "fail"}
+{"Package": "pkg", "Action": "fail"}
+`,
+		`{"Package": "pkg", "Test": "TestB", "Action": "run"}
+{"Package": "pkg", "Test": "TestB", "Action": "pass"}
+{"Package": "pkg", "Action": "pass"}
+`,
+	}
+	fn := func(t *testing.T, rootCases bool) {
+		var runs [][]string
+		reset := patchStartGoTestFn(func(args []string) *proc {
+			var result error
+			if len(runs) == 0 {
+				result = newExitCode("failed", 1)
+			}
+			out := outputs[len(runs)]
+			runs = append(runs, args)
+			return &proc{
+				cmd:    fakeWaiter{result: result},
+				stdout: strings.NewReader(out),
+				stderr: bytes.NewReader(nil),
+			}
+		})
+		defer reset()
+
+		opts := &options{
+			rawCommand:                   true,
+			args:                         []string{"./test.test"},
+			format:                       "testname",
+			quarantineFile:               quarantineFile.Path(),
+			rerunFailsMaxAttempts:        2,
+			rerunFailsMaxInitialFailures: 1,
+			rerunFailsRunRootCases:       rootCases,
+			stdout:                       new(bytes.Buffer),
+			stderr:                       os.Stderr,
+			hideSummary:                  newHideSummaryValue(),
+		}
+		assert.NilError(t, run(opts))
+		expected := [][]string{
+			{"./test.test"},
+			{"./test.test", "-test.run=^TestB$", "pkg"},
+		}
+		assert.DeepEqual(t, runs, expected)
+	}
+
+	runCase(t, "unique failures", func(t *testing.T) {
+		fn(t, false)
+	})
+	runCase(t, "root cases", func(t *testing.T) {
+		fn(t, true)
+	})
+}
+
+func TestRun_QuarantineFile_RerunFailuresAreFiltered(t *testing.T) {
+	quarantineFile := fs.NewFile(t, "quarantine.yaml", fs.WithContent(`
+tests:
+  - package: pkg
+    test: TestA/sub
+`))
+
+	outputs := []string{
+		`{"Package": "pkg", "Test": "TestA", "Action": "run"}
+{"Package": "pkg", "Test": "TestA/sub", "Action": "run"}
+{"Package": "pkg", "Test": "TestA/sub", "Action": "fail"}
+{"Package": "pkg", "Test": "TestA/other", "Action": "run"}
+{"Package": "pkg", "Test": "TestA/other", "Action": "fail"}
+{"Package": "pkg", "Test": "TestA", "Action": "fail"}
+{"Package": "pkg", "Action": "fail"}
+`,
+		`{"Package": "pkg", "Test": "TestA", "Action": "run"}
+{"Package": "pkg", "Test": "TestA/sub", "Action": "run"}
+{"Package": "pkg", "Test": "TestA/sub", "Action": "fail"}
+{"Package": "pkg", "Test": "TestA/other", "Action": "run"}
+{"Package": "pkg", "Test": "TestA/other", "Action": "pass"}
+{"Package": "pkg", "Test": "TestA", "Action": "fail"}
+{"Package": "pkg", "Action": "fail"}
+`,
+	}
+	var runs [][]string
+	reset := patchStartGoTestFn(func(args []string) *proc {
+		out := outputs[len(runs)]
+		runs = append(runs, args)
+		return &proc{
+			cmd:    fakeWaiter{result: newExitCode("failed", 1)},
+			stdout: strings.NewReader(out),
+			stderr: bytes.NewReader(nil),
+		}
+	})
+	defer reset()
+
+	opts := &options{
+		rawCommand:                   true,
+		args:                         []string{"./test.test"},
+		format:                       "testname",
+		quarantineFile:               quarantineFile.Path(),
+		rerunFailsMaxAttempts:        3,
+		rerunFailsMaxInitialFailures: 1,
+		rerunFailsRunRootCases:       true,
+		stdout:                       new(bytes.Buffer),
+		stderr:                       os.Stderr,
+		hideSummary:                  newHideSummaryValue(),
+	}
+	assert.NilError(t, run(opts))
+	// TestA is not run a third time, because the only failure of the rerun
+	// is the quarantined subtest.
+	expected := [][]string{
+		{"./test.test"},
+		{"./test.test", "-test.run=^TestA$", "pkg"},
+	}
+	assert.DeepEqual(t, runs, expected)
+}
+
+func TestRun_ClosesFormatterWhenGoTestFailsToStart(t *testing.T) {
+	plugin := buildFormatPlugin(t)
+	out := new(bytes.Buffer)
+	opts := &options{
+		rawCommand:  true,
+		args:        []string{filepath.Join(t.TempDir(), "does-not-exist")},
+		format:      "exec:" + plugin + " print-close",
+		stdout:      out,
+		stderr:      new(bytes.Buffer),
+		hideSummary: newHideSummaryValue(),
+	}
+	err := run(opts)
+	assert.ErrorContains(t, err, "does-not-exist")
+	assert.Equal(t, out.String(), "stdin closed\n")
+}
+
 func TestRun_InputFromStdin(t *testing.T) {
 	stdin := os.Stdin
 	t.Cleanup(func() { os.Stdin = stdin })
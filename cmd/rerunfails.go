@@ -1,13 +1,17 @@
 package cmd
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"os"
 	"regexp"
 	"sort"
 	"strings"
+	"sync"
 
+	"golang.org/x/sync/errgroup"
+	"gotest.tools/gotestsum/internal/quarantine"
 	"gotest.tools/gotestsum/testjson"
 )
 
@@ -34,75 +38,405 @@ func newRerunOptsFromTestCase(tc testjson.TestCase) rerunOpts {
 	}
 }
 
+// newRerunOptsFromTestCases returns the rerunOpts to run all of tcs with a
+// single go test command. All of tcs must be in the same package.
+func newRerunOptsFromTestCases(tcs []testjson.TestCase) rerunOpts {
+	names := make([]testjson.TestName, 0, len(tcs))
+	for _, tc := range tcs {
+		names = append(names, tc.Test)
+	}
+	return rerunOpts{
+		runFlag: goTestRunFlagForTestCases(names),
+		pkg:     tcs[0].Package,
+	}
+}
+
 type testCaseFilter func([]testjson.TestCase) []testjson.TestCase
 
-func rerunFailsFilter(o *options) testCaseFilter {
-	if o.rerunFailsRunRootCases {
-		return func(tcs []testjson.TestCase) []testjson.TestCase {
-			var result []testjson.TestCase
-			for _, tc := range tcs {
-				if !tc.Test.IsSubTest() {
-					result = append(result, tc)
-				}
+// rerunFailsFilter returns the filter that selects the failed tests to rerun.
+// Quarantined tests are removed after the parents of failed subtests, so that
+// the parent of a quarantined subtest is not rerun when that subtest is its
+// only failure.
+func rerunFailsFilter(opts *options, list *quarantine.List) testCaseFilter {
+	return func(tcs []testjson.TestCase) []testjson.TestCase {
+		// FilterFailedUnique sorts the slice, so it is given a copy to keep
+		// the order of tcs.
+		unique := testjson.FilterFailedUnique(append([]testjson.TestCase(nil), tcs...))
+		unique = list.Exclude(unique)
+		if !opts.rerunFailsRunRootCases {
+			return unique
+		}
+
+		roots := make(map[string]bool)
+		for _, tc := range unique {
+			root, _ := tc.Test.Split()
+			roots[tc.Package+"."+root] = true
+		}
+		var result []testjson.TestCase
+		for _, tc := range tcs {
+			if !tc.Test.IsSubTest() && roots[tc.Package+"."+tc.Test.Name()] {
+				result = append(result, tc)
 			}
-			return result
 		}
+		return result
 	}
-	return testjson.FilterFailedUnique
 }
 
 func rerunFailed(ctx context.Context, opts *options, scanConfig testjson.ScanConfig) error {
+	tcFilter := rerunFailsFilter(opts, nil)
+	return rerunFailures(ctx, opts, scanConfig, tcFilter, scanConfig.Execution.Failed())
+}
+
+// rerunFailures reruns the failures selected by tcFilter, and then any tests
+// that fail in the rerun, until they pass or the maximum number of attempts is
+// reached.
+func rerunFailures(
+	ctx context.Context,
+	opts *options,
+	scanConfig testjson.ScanConfig,
+	tcFilter testCaseFilter,
+	failures []testjson.TestCase,
+) error {
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
-	tcFilter := rerunFailsFilter(opts)
 
-	rec := newFailureRecorderFromExecution(scanConfig.Execution)
-	for attempts := 0; rec.count() > 0 && attempts < opts.rerunFailsMaxAttempts; attempts++ {
+	rec := &failureRecorder{failures: failures}
+	for attempts := 0; attempts < opts.rerunFailsMaxAttempts; attempts++ {
+		tcs := tcFilter(rec.failures)
+		if len(tcs) == 0 {
+			break
+		}
 		testjson.PrintSummary(opts.stdout, scanConfig.Execution, testjson.SummarizeNone)
 		opts.stdout.Write([]byte("\n")) //nolint:errcheck
 
 		nextRec := newFailureRecorder(scanConfig.Handler)
-		for _, tc := range tcFilter(rec.failures) {
-			goTestProc, err := startGoTestFn(ctx, "", goTestCmdArgs(opts, newRerunOptsFromTestCase(tc)))
-			if err != nil {
-				return err
-			}
-
-			cfg := testjson.ScanConfig{
-				RunID:     attempts + 1,
-				Stdout:    goTestProc.stdout,
-				Stderr:    goTestProc.stderr,
-				Handler:   nextRec,
-				Execution: scanConfig.Execution,
-				Stop:      cancel,
-			}
-			if _, err := testjson.ScanTestOutput(cfg); err != nil {
-				return err
-			}
-			exitErr := goTestProc.cmd.Wait()
-			if exitErr != nil {
-				nextRec.lastErr = exitErr
-			}
-			if err := hasErrors(exitErr, scanConfig.Execution, opts); err != nil {
-				return err
-			}
+		cfg := testjson.ScanConfig{
+			RunID:     attempts + 1,
+			Execution: scanConfig.Execution,
+			Stop:      cancel,
+		}
+		group, groupCtx := errgroup.WithContext(ctx)
+		group.SetLimit(max(opts.rerunFailsParallel, 1))
+		for _, pkgTCs := range groupByPackage(tcs) {
+			group.Go(func() error {
+				return rerunPackage(groupCtx, opts, cfg, nextRec, pkgTCs)
+			})
+		}
+		if err := group.Wait(); err != nil {
+			return err
 		}
 		rec = nextRec
 	}
 	return rec.lastErr
 }
 
+// rerunPackage reruns the failed tests of a single package. Each test is run
+// with a separate go test command, unless --rerun-fails-batch is set.
+func rerunPackage(
+	ctx context.Context,
+	opts *options,
+	cfg testjson.ScanConfig,
+	rec *failureRecorder,
+	tcs []testjson.TestCase,
+) error {
+	if !opts.rerunFailsBatch || len(tcs) == 1 {
+		return rerunEach(ctx, opts, cfg, rec, tcs)
+	}
+
+	batch, err := runGoTestBatch(ctx, opts, newRerunOptsFromTestCases(tcs))
+	if err != nil {
+		return err
+	}
+
+	// A panic stops the test binary, so some tests may not have run, and the
+	// failure of another test may have been caused by the panic. The events of
+	// a batch that panicked are discarded, and each test is run again on its
+	// own, so that a panic in one of those runs aborts the rerun, the same as
+	// it would without --rerun-fails-batch.
+	if batch.panicked {
+		return rerunEach(ctx, opts, cfg, rec, tcs)
+	}
+
+	run, err := batch.replay(cfg, rec)
+	if err != nil {
+		return err
+	}
+	rec.record(run)
+	if err := rec.hasErrors(run.exitErr, false, cfg.Execution, opts); err != nil {
+		return err
+	}
+	var notRun []testjson.TestCase
+	for _, tc := range tcs {
+		if _, ok := run.results[tc.Test]; !ok {
+			notRun = append(notRun, tc)
+		}
+	}
+	return rerunEach(ctx, opts, cfg, rec, notRun)
+}
+
+// batchRun is the output of a go test command that reran a batch of tests.
+type batchRun struct {
+	stdout, stderr []byte
+	exitErr        error
+	// panicked is true if the output of the command looked like a panic.
+	panicked bool
+}
+
+// runGoTestBatch starts a go test command with rerunOpts, and reads all of its
+// output. The output is scanned into a new Execution, to find a panic, without
+// changing the Execution of the run, or sending any events to the handler.
+func runGoTestBatch(ctx context.Context, opts *options, rerunOpts rerunOpts) (*batchRun, error) {
+	goTestProc, err := startGoTestFn(ctx, "", goTestStdin(opts), goTestCmdArgs(opts, rerunOpts))
+	if err != nil {
+		return nil, err
+	}
+	stdout, stderr, err := readOutput(goTestProc)
+	if err != nil {
+		return nil, err
+	}
+	batch := &batchRun{exitErr: goTestProc.cmd.Wait()}
+
+	scan := &rerunRecorder{EventHandler: discardEvents{}, results: make(map[testjson.TestName]testjson.Action)}
+	_, err = testjson.ScanTestOutput(testjson.ScanConfig{
+		Stdout:  bytes.NewReader(stdout.Bytes()),
+		Stderr:  bytes.NewReader(stderr.Bytes()),
+		Handler: scan,
+	})
+	if err != nil {
+		return nil, err
+	}
+	batch.stdout, batch.stderr, batch.panicked = stdout.Bytes(), stderr.Bytes(), scan.panicked
+	return batch, nil
+}
+
+// replay scans the output of the batch using cfg, which adds the events to
+// the Execution and sends them to the handler of rec.
+func (b *batchRun) replay(cfg testjson.ScanConfig, rec *failureRecorder) (*rerunRecorder, error) {
+	run := &rerunRecorder{
+		EventHandler: rec.EventHandler,
+		results:      make(map[testjson.TestName]testjson.Action),
+		exitErr:      b.exitErr,
+	}
+	cfg.Stdout = bytes.NewReader(b.stdout)
+	cfg.Stderr = bytes.NewReader(b.stderr)
+	cfg.Handler = run
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if _, err := testjson.ScanTestOutput(cfg); err != nil {
+		return nil, err
+	}
+	return run, nil
+}
+
+// discardEvents is an EventHandler that ignores all events.
+type discardEvents struct{}
+
+func (discardEvents) Event(testjson.TestEvent, *testjson.Execution) error {
+	return nil
+}
+
+func (discardEvents) Err(string) error {
+	return nil
+}
+
+// rerunEach runs each of tcs with a separate go test command.
+func rerunEach(
+	ctx context.Context,
+	opts *options,
+	cfg testjson.ScanConfig,
+	rec *failureRecorder,
+	tcs []testjson.TestCase,
+) error {
+	for _, tc := range tcs {
+		run, err := runGoTestForRerun(ctx, opts, cfg, rec, newRerunOptsFromTestCase(tc))
+		if err != nil {
+			return err
+		}
+		rec.record(run)
+		if err := rec.hasErrors(run.exitErr, run.panicked, cfg.Execution, opts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runGoTestForRerun starts a go test command with rerunOpts, and scans the
+// output using cfg. The failures of the command are returned in the
+// rerunRecorder, and must be added to rec with failureRecorder.record.
+//
+// When go test commands run in parallel, all the output of the command is
+// read before any of it is scanned, so that the output of one command is not
+// interleaved with the output of another.
+func runGoTestForRerun(
+	ctx context.Context,
+	opts *options,
+	cfg testjson.ScanConfig,
+	rec *failureRecorder,
+	rerunOpts rerunOpts,
+) (*rerunRecorder, error) {
+	goTestProc, err := startGoTestFn(ctx, "", goTestStdin(opts), goTestCmdArgs(opts, rerunOpts))
+	if err != nil {
+		return nil, err
+	}
+
+	run := &rerunRecorder{EventHandler: rec.EventHandler, results: make(map[testjson.TestName]testjson.Action)}
+	cfg.Stdout = goTestProc.stdout
+	cfg.Stderr = goTestProc.stderr
+	cfg.Handler = run
+	if opts.rerunFailsParallel > 1 {
+		cfg.Stdout, cfg.Stderr, err = readOutput(goTestProc)
+		if err != nil {
+			return nil, err
+		}
+		run.exitErr = goTestProc.cmd.Wait()
+	}
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if _, err := testjson.ScanTestOutput(cfg); err != nil {
+		return nil, err
+	}
+	if opts.rerunFailsParallel <= 1 {
+		run.exitErr = goTestProc.cmd.Wait()
+	}
+	return run, nil
+}
+
+// readOutput reads all of the stdout and stderr of goTestProc.
+func readOutput(goTestProc *proc) (*bytes.Buffer, *bytes.Buffer, error) {
+	stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+	var group errgroup.Group
+	group.Go(func() error {
+		_, err := stdout.ReadFrom(goTestProc.stdout)
+		return err
+	})
+	group.Go(func() error {
+		_, err := stderr.ReadFrom(goTestProc.stderr)
+		return err
+	})
+	if err := group.Wait(); err != nil {
+		return nil, nil, fmt.Errorf("failed to read go test output: %w", err)
+	}
+	return stdout, stderr, nil
+}
+
+// groupByPackage returns tcs grouped by package, in the order each package
+// first appears in tcs.
+func groupByPackage(tcs []testjson.TestCase) [][]testjson.TestCase {
+	var result [][]testjson.TestCase
+	index := make(map[string]int)
+	for _, tc := range tcs {
+		i, ok := index[tc.Package]
+		if !ok {
+			i = len(result)
+			index[tc.Package] = i
+			result = append(result, nil)
+		}
+		result[i] = append(result[i], tc)
+	}
+	return result
+}
+
+// rerunFailsFromFile reads the --jsonfile of an earlier run from
+// opts.rerunFailsFrom, and reruns the tests that failed in that run. The events
+// of the earlier run are sent to the event handler before the rerun, so that
+// the --jsonfile and the reports include both the earlier run and the rerun.
+func rerunFailsFromFile(ctx context.Context, opts *options) error {
+	// The file is read before the event handler is created, so that the
+	// --jsonfile may be the same file.
+	raw, err := os.ReadFile(opts.rerunFailsFrom)
+	if err != nil {
+		return fmt.Errorf("failed to read --rerun-fails-from file: %w", err)
+	}
+
+	handler, err := newEventHandler(opts)
+	if err != nil {
+		return err
+	}
+	defer handler.Close() //nolint:errcheck
+	exec, err := testjson.ScanTestOutput(testjson.ScanConfig{
+		Stdout:                   bytes.NewReader(raw),
+		Handler:                  handler,
+		IgnoreNonJSONOutputLines: opts.ignoreNonJSONOutputLines,
+		KeepPassedOutput:         opts.keepPassedOutput(),
+	})
+	handler.Flush()
+	if err != nil {
+		return finishRun(opts, handler, exec, err)
+	}
+
+	failures := lastAttemptFailed(exec)
+	exitErr := runExitError(exec, failures)
+	if len(failures) == 0 {
+		return finishRun(opts, handler, exec, exitErr)
+	}
+	return rerunAndFinishRun(ctx, opts, handler, exec, exitErr, failures)
+}
+
+// runExitError returns the error that the go test command of an earlier run
+// would have exited with, because the exit code is not recorded in the
+// --jsonfile. failures are the tests that failed in the last attempt. A build
+// failure, or a TestMain failure, fails the run even if no test failed.
+func runExitError(exec *testjson.Execution, failures []testjson.TestCase) error {
+	if len(exec.Errors()) > 0 || len(failures) > 0 {
+		return exitError{num: 1}
+	}
+	for _, name := range exec.Packages() {
+		if exec.Package(name).TestMainFailed() {
+			return exitError{num: 1}
+		}
+	}
+	return nil
+}
+
+// lastAttemptFailed returns the failed tests in exec, excluding any test that
+// passed in a later attempt. A --jsonfile from a run that used --rerun-fails
+// contains every attempt of a test, and only the tests that never passed
+// should be run again. When a test failed more than once only the last
+// failure is returned.
+func lastAttemptFailed(exec *testjson.Execution) []testjson.TestCase {
+	var result []testjson.TestCase
+	for _, tc := range exec.Failed() {
+		pkg := exec.Package(tc.Package)
+		if pkg.LastFailedByName(tc.Test.Name()).ID != tc.ID {
+			continue
+		}
+		if passedAfter(pkg, tc) {
+			continue
+		}
+		result = append(result, tc)
+	}
+	return result
+}
+
+func passedAfter(pkg *testjson.Package, failed testjson.TestCase) bool {
+	for _, tc := range pkg.Passed {
+		if tc.Test == failed.Test && tc.ID > failed.ID {
+			return true
+		}
+	}
+	return false
+}
+
 // startGoTestFn is a shim for testing
 var startGoTestFn = startGoTest
 
 func hasErrors(err error, exec *testjson.Execution, opts *options) error {
+	return hasRunErrors(err, exec.HasPanic(), exec, opts)
+}
+
+// hasRunErrors is hasErrors for a single go test command. panicked is passed
+// separately because Execution.HasPanic includes the output of every go test
+// command scanned into the Execution.
+func hasRunErrors(err error, panicked bool, exec *testjson.Execution, opts *options) error {
 	switch {
 	case len(exec.Errors()) > 0:
 		return fmt.Errorf("rerun aborted because previous run had errors")
 	// Exit code 0 and 1 are expected.
 	case ExitCodeWithDefault(err) > 1:
 		return fmt.Errorf("unexpected go test exit code: %v", err)
-	case exec.HasPanic():
+	case panicked:
 		return fmt.Errorf("rerun aborted because previous run had a suspected panic and some test may not have run")
 	case exec.HasDataRace() && opts.rerunFailsMaxAttempts > 0 && opts.rerunFailsAbortOnDataRace:
 		return fmt.Errorf("rerun aborted because previous run had a data race")
@@ -115,45 +449,97 @@ type failureRecorder struct {
 	testjson.EventHandler
 	failures []testjson.TestCase
 	lastErr  error
+	// mu is held while the output of a go test command is scanned, because
+	// the commands started by --rerun-fails-parallel share the Execution, the
+	// EventHandler, and the failureRecorder.
+	mu sync.Mutex
 }
 
 func newFailureRecorder(handler testjson.EventHandler) *failureRecorder {
 	return &failureRecorder{EventHandler: handler}
 }
 
-func newFailureRecorderFromExecution(exec *testjson.Execution) *failureRecorder {
-	return &failureRecorder{failures: exec.Failed()}
+// record adds the failures and the exit error of run to r.
+func (r *failureRecorder) record(run *rerunRecorder) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.failures = append(r.failures, run.failures...)
+	if run.exitErr != nil {
+		r.lastErr = run.exitErr
+	}
+}
+
+// hasErrors calls hasRunErrors while holding r.mu, so that the Execution is not
+// read while the output of another go test command is being scanned.
+func (r *failureRecorder) hasErrors(
+	exitErr error,
+	panicked bool,
+	exec *testjson.Execution,
+	opts *options,
+) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return hasRunErrors(exitErr, panicked, exec, opts)
+}
+
+// rerunRecorder records the result of a single go test command started by
+// rerunFailed.
+type rerunRecorder struct {
+	testjson.EventHandler
+	// results is the terminal action of each test which passed, failed, or was
+	// skipped.
+	results map[testjson.TestName]testjson.Action
+	// failures are the tests which failed.
+	failures []testjson.TestCase
+	// panicked is true if the output of the command looked like a panic.
+	panicked bool
+	exitErr  error
 }
 
-func (r *failureRecorder) Event(event testjson.TestEvent, execution *testjson.Execution) error {
-	if !event.PackageEvent() && event.Action == testjson.ActionFail {
-		pkg := execution.Package(event.Package)
-		tc := pkg.LastFailedByName(event.Test)
-		r.failures = append(r.failures, tc)
+func (r *rerunRecorder) Event(event testjson.TestEvent, execution *testjson.Execution) error {
+	switch {
+	case event.Action == testjson.ActionOutput && strings.HasPrefix(event.Output, "panic: "):
+		r.panicked = true
+	case !event.PackageEvent() && event.Action.IsTerminal():
+		r.results[testjson.TestName(event.Test)] = event.Action
+		if event.Action == testjson.ActionFail {
+			pkg := execution.Package(event.Package)
+			r.failures = append(r.failures, pkg.LastFailedByName(event.Test))
+		}
 	}
 	return r.EventHandler.Event(event, execution)
 }
 
-func (r *failureRecorder) count() int {
-	return len(r.failures)
+func goTestRunFlagForTestCase(test testjson.TestName) string {
+	return "-test.run=" + testNamePattern(test)
 }
 
-func goTestRunFlagForTestCase(test testjson.TestName) string {
-	if test.IsSubTest() {
-		parts := strings.Split(string(test), "/")
-		var sb strings.Builder
-		sb.WriteString("-test.run=")
-		for i, p := range parts {
-			if i > 0 {
-				sb.WriteByte('/')
-			}
-			sb.WriteByte('^')
-			sb.WriteString(regexp.QuoteMeta(p))
-			sb.WriteByte('$')
+// goTestRunFlagForTestCases returns a -test.run flag that matches all of
+// tests. Each test is a separate alternative of the pattern, which go test
+// matches independently, so the subtest patterns of one test do not filter the
+// subtests of another.
+func goTestRunFlagForTestCases(tests []testjson.TestName) string {
+	patterns := make([]string, 0, len(tests))
+	for _, test := range tests {
+		patterns = append(patterns, testNamePattern(test))
+	}
+	return "-test.run=" + strings.Join(patterns, "|")
+}
+
+// testNamePattern returns a pattern for the -test.run flag that matches only
+// test, and the subtests of test.
+func testNamePattern(test testjson.TestName) string {
+	parts := strings.Split(string(test), "/")
+	var sb strings.Builder
+	for i, p := range parts {
+		if i > 0 {
+			sb.WriteByte('/')
 		}
-		return sb.String()
+		sb.WriteByte('^')
+		sb.WriteString(regexp.QuoteMeta(p))
+		sb.WriteByte('$')
 	}
-	return "-test.run=^" + regexp.QuoteMeta(test.Name()) + "$"
+	return sb.String()
 }
 
 func writeRerunFailsReport(opts *options, exec *testjson.Execution) error {
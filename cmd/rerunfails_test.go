@@ -4,9 +4,12 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"os"
+	"sort"
 	"strings"
 	"testing"
+	"time"
 
 	"gotest.tools/gotestsum/testjson"
 	"gotest.tools/v3/assert"
@@ -94,6 +97,17 @@ func TestGoTestRunFlagFromTestCases(t *testing.T) {
 	}
 }
 
+func TestGoTestRunFlagForTestCases(t *testing.T) {
+	actual := goTestRunFlagForTestCases([]testjson.TestName{
+		"TestOne",
+		"TestTwo/SubtestA",
+		"TestTwo/Subtest(B)|[100]",
+		"TestThree/Nested/SubtestA",
+	})
+	expected := `-test.run=^TestOne$|^TestTwo$/^SubtestA$|^TestTwo$/^Subtest\(B\)\|\[100\]$|^TestThree$/^Nested$/^SubtestA$`
+	assert.Equal(t, actual, expected)
+}
+
 func TestRerunFailed_ReturnsAnErrorWhenTheLastTestIsSuccessful(t *testing.T) {
 	type result struct {
 		out string
@@ -144,6 +158,306 @@ func TestRerunFailed_ReturnsAnErrorWhenTheLastTestIsSuccessful(t *testing.T) {
 	assert.Error(t, err, "run-failed-3")
 }
 
+func TestRerunFailed_Batch(t *testing.T) {
+	var runs [][]string
+	fn := func(args []string) *proc {
+		runs = append(runs, args)
+		return &proc{
+			cmd: fakeWaiter{},
+			stdout: strings.NewReader(dedentOutput(`
+				{"Package": "pkg", "Test": "TestOne", "Action": "pass"}
+				{"Package": "pkg", "Test": "TestTwo/sub", "Action": "pass"}
+				{"Package": "pkg", "Test": "TestTwo", "Action": "pass"}
+				{"Package": "pkg", "Action": "pass"}
+			`)),
+			stderr: bytes.NewReader(nil),
+		}
+	}
+	reset := patchStartGoTestFn(fn)
+	defer reset()
+
+	exec := newExecution(t, dedentOutput(`
+		{"Package": "pkg", "Test": "TestOne", "Action": "run"}
+		{"Package": "pkg", "Test": "TestOne", "Action": "fail"}
+		{"Package": "pkg", "Test": "TestTwo", "Action": "run"}
+		{"Package": "pkg", "Test": "TestTwo/sub", "Action": "run"}
+		{"Package": "pkg", "Test": "TestTwo/sub", "Action": "fail"}
+		{"Package": "pkg", "Test": "TestTwo", "Action": "fail"}
+		{"Package": "pkg", "Action": "fail"}
+		{"Package": "pkg2", "Test": "TestThree", "Action": "run"}
+		{"Package": "pkg2", "Test": "TestThree", "Action": "fail"}
+		{"Package": "pkg2", "Action": "fail"}
+	`))
+	opts := &options{
+		rerunFailsMaxInitialFailures: 10,
+		rerunFailsMaxAttempts:        1,
+		rerunFailsBatch:              true,
+		stdout:                       new(bytes.Buffer),
+	}
+	err := rerunFailed(context.Background(), opts, testjson.ScanConfig{
+		Execution: exec,
+		Handler:   noopHandler{},
+	})
+	assert.NilError(t, err)
+
+	expected := [][]string{
+		{"go", "test", "-json", "-test.run=^TestOne$|^TestTwo$/^sub$", "pkg"},
+		{"go", "test", "-json", "-test.run=^TestThree$", "pkg2"},
+	}
+	assert.DeepEqual(t, runs, expected)
+}
+
+func TestRerunFailed_BatchRerunsTestsAloneAfterAPanic(t *testing.T) {
+	outputs := map[string]string{
+		"-test.run=^TestOne$|^TestTwo$|^TestThree$": `
+			{"Package": "pkg", "Test": "TestOne", "Action": "run"}
+			{"Package": "pkg", "Test": "TestOne", "Action": "pass"}
+			{"Package": "pkg", "Test": "TestTwo", "Action": "run"}
+			{"Package": "pkg", "Test": "TestTwo", "Action": "output", "Output": "panic: boom\n"}
+			{"Package": "pkg", "Test": "TestTwo", "Action": "fail"}
+			{"Package": "pkg", "Action": "fail"}`,
+		"-test.run=^TestOne$": `
+			{"Package": "pkg", "Test": "TestOne", "Action": "run"}
+			{"Package": "pkg", "Test": "TestOne", "Action": "pass"}
+			{"Package": "pkg", "Action": "pass"}`,
+		"-test.run=^TestTwo$": `
+			{"Package": "pkg", "Test": "TestTwo", "Action": "run"}
+			{"Package": "pkg", "Test": "TestTwo", "Action": "pass"}
+			{"Package": "pkg", "Action": "pass"}`,
+		"-test.run=^TestThree$": `
+			{"Package": "pkg", "Test": "TestThree", "Action": "run"}
+			{"Package": "pkg", "Test": "TestThree", "Action": "fail"}
+			{"Package": "pkg", "Action": "fail"}`,
+	}
+	var runs []string
+	fn := func(args []string) *proc {
+		runFlag := args[3]
+		runs = append(runs, runFlag)
+		return &proc{
+			cmd:    fakeWaiter{result: newExitCode("failed", 1)},
+			stdout: strings.NewReader(dedentOutput(outputs[runFlag])),
+			stderr: bytes.NewReader(nil),
+		}
+	}
+	reset := patchStartGoTestFn(fn)
+	defer reset()
+
+	exec := newExecution(t, dedentOutput(`
+		{"Package": "pkg", "Test": "TestOne", "Action": "run"}
+		{"Package": "pkg", "Test": "TestOne", "Action": "fail"}
+		{"Package": "pkg", "Test": "TestTwo", "Action": "run"}
+		{"Package": "pkg", "Test": "TestTwo", "Action": "fail"}
+		{"Package": "pkg", "Test": "TestThree", "Action": "run"}
+		{"Package": "pkg", "Test": "TestThree", "Action": "fail"}
+		{"Package": "pkg", "Action": "fail"}
+	`))
+	opts := &options{
+		rerunFailsMaxInitialFailures: 10,
+		rerunFailsMaxAttempts:        1,
+		rerunFailsBatch:              true,
+		stdout:                       new(bytes.Buffer),
+	}
+	handler := &eventRecorder{}
+	err := rerunFailed(context.Background(), opts, testjson.ScanConfig{
+		Execution: exec,
+		Handler:   handler,
+	})
+	assert.Error(t, err, "failed")
+	assert.DeepEqual(t, runs, []string{
+		"-test.run=^TestOne$|^TestTwo$|^TestThree$",
+		"-test.run=^TestOne$",
+		"-test.run=^TestTwo$",
+		"-test.run=^TestThree$",
+	})
+
+	// The events of the batch that panicked are not added to the Execution,
+	// or sent to the handler.
+	pkg := exec.Package("pkg")
+	assert.Equal(t, pkg.LastFailedByName("TestThree").RunID, 1)
+	assert.Equal(t, pkg.LastFailedByName("TestTwo").RunID, 0)
+	assert.Equal(t, len(pkg.Passed), 2)
+	for _, event := range handler.events {
+		assert.Assert(t, event.Output != "panic: boom\n")
+	}
+}
+
+func TestRerunFailed_BatchIgnoresThePanickedRunWhenTestsPassAlone(t *testing.T) {
+	outputs := map[string]string{
+		"-test.run=^TestOne$|^TestTwo$": `
+			{"Package": "pkg", "Test": "TestOne", "Action": "run"}
+			{"Package": "pkg", "Test": "TestOne", "Action": "output", "Output": "panic: boom\n"}
+			{"Package": "pkg", "Test": "TestOne", "Action": "fail"}
+			{"Package": "pkg", "Action": "fail"}`,
+		"-test.run=^TestOne$": `
+			{"Package": "pkg", "Test": "TestOne", "Action": "run"}
+			{"Package": "pkg", "Test": "TestOne", "Action": "pass"}
+			{"Package": "pkg", "Action": "pass"}`,
+		"-test.run=^TestTwo$": `
+			{"Package": "pkg", "Test": "TestTwo", "Action": "run"}
+			{"Package": "pkg", "Test": "TestTwo", "Action": "pass"}
+			{"Package": "pkg", "Action": "pass"}`,
+	}
+	var runs []string
+	fn := func(args []string) *proc {
+		runFlag := args[3]
+		runs = append(runs, runFlag)
+		var result error
+		if strings.Contains(runFlag, "|") {
+			result = newExitCode("failed", 1)
+		}
+		return &proc{
+			cmd:    fakeWaiter{result: result},
+			stdout: strings.NewReader(dedentOutput(outputs[runFlag])),
+			stderr: bytes.NewReader(nil),
+		}
+	}
+	reset := patchStartGoTestFn(fn)
+	defer reset()
+
+	exec := newExecution(t, dedentOutput(`
+		{"Package": "pkg", "Test": "TestOne", "Action": "run"}
+		{"Package": "pkg", "Test": "TestOne", "Action": "fail"}
+		{"Package": "pkg", "Test": "TestTwo", "Action": "run"}
+		{"Package": "pkg", "Test": "TestTwo", "Action": "fail"}
+		{"Package": "pkg", "Action": "fail"}
+	`))
+	opts := &options{
+		rerunFailsMaxInitialFailures: 10,
+		rerunFailsMaxAttempts:        2,
+		rerunFailsBatch:              true,
+		stdout:                       new(bytes.Buffer),
+	}
+	err := rerunFailed(context.Background(), opts, testjson.ScanConfig{
+		Execution: exec,
+		Handler:   noopHandler{},
+	})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, runs, []string{
+		"-test.run=^TestOne$|^TestTwo$",
+		"-test.run=^TestOne$",
+		"-test.run=^TestTwo$",
+	})
+}
+
+func TestRerunFailed_BatchAbortsWhenAnIsolatedRerunPanics(t *testing.T) {
+	fn := func([]string) *proc {
+		return &proc{
+			cmd: fakeWaiter{result: newExitCode("failed", 1)},
+			stdout: strings.NewReader(dedentOutput(`
+				{"Package": "pkg", "Test": "TestOne", "Action": "run"}
+				{"Package": "pkg", "Test": "TestOne", "Action": "output", "Output": "panic: boom\n"}
+				{"Package": "pkg", "Test": "TestOne", "Action": "fail"}
+				{"Package": "pkg", "Action": "fail"}
+			`)),
+			stderr: bytes.NewReader(nil),
+		}
+	}
+	reset := patchStartGoTestFn(fn)
+	defer reset()
+
+	opts := &options{
+		rerunFailsMaxInitialFailures: 10,
+		rerunFailsMaxAttempts:        2,
+		rerunFailsBatch:              true,
+		stdout:                       new(bytes.Buffer),
+	}
+	err := rerunFailed(context.Background(), opts, testjson.ScanConfig{
+		Execution: newExecutionWithTwoFailures(t),
+		Handler:   noopHandler{},
+	})
+	assert.Error(t, err, "rerun aborted because previous run had a suspected panic and some test may not have run")
+}
+
+func TestRerunFailed_Parallel(t *testing.T) {
+	// The rerun of pkg can not finish until the rerun of pkg2 has started,
+	// which only happens when the reruns run in parallel.
+	pkg2Started := make(chan struct{})
+	fn := func(args []string) *proc {
+		pkg := args[len(args)-1]
+		var stdout io.Reader = strings.NewReader(fmt.Sprintf(dedentOutput(`
+			{"Package": %[1]q, "Test": "TestOne", "Action": "run"}
+			{"Package": %[1]q, "Test": "TestOne", "Action": "output", "Output": "one\n"}
+			{"Package": %[1]q, "Test": "TestOne", "Action": "pass"}
+			{"Package": %[1]q, "Test": "TestTwo", "Action": "run"}
+			{"Package": %[1]q, "Test": "TestTwo", "Action": "output", "Output": "two\n"}
+			{"Package": %[1]q, "Test": "TestTwo", "Action": "pass"}
+			{"Package": %[1]q, "Action": "pass"}
+		`), pkg))
+		if pkg == "pkg" {
+			stdout = &waitReader{wait: pkg2Started, Reader: stdout}
+		} else {
+			close(pkg2Started)
+		}
+		return &proc{cmd: fakeWaiter{}, stdout: stdout, stderr: bytes.NewReader(nil)}
+	}
+	reset := patchStartGoTestFn(fn)
+	defer reset()
+
+	exec := newExecution(t, dedentOutput(`
+		{"Package": "pkg", "Test": "TestOne", "Action": "run"}
+		{"Package": "pkg", "Test": "TestOne", "Action": "fail"}
+		{"Package": "pkg", "Test": "TestTwo", "Action": "run"}
+		{"Package": "pkg", "Test": "TestTwo", "Action": "fail"}
+		{"Package": "pkg", "Action": "fail"}
+		{"Package": "pkg2", "Test": "TestOne", "Action": "run"}
+		{"Package": "pkg2", "Test": "TestOne", "Action": "fail"}
+		{"Package": "pkg2", "Test": "TestTwo", "Action": "run"}
+		{"Package": "pkg2", "Test": "TestTwo", "Action": "fail"}
+		{"Package": "pkg2", "Action": "fail"}
+	`))
+	opts := &options{
+		rerunFailsMaxInitialFailures: 10,
+		rerunFailsMaxAttempts:        1,
+		rerunFailsParallel:           2,
+		rerunFailsBatch:              true,
+		stdout:                       new(bytes.Buffer),
+	}
+	handler := &eventRecorder{}
+	err := rerunFailed(context.Background(), opts, testjson.ScanConfig{
+		Execution: exec,
+		Handler:   handler,
+	})
+	assert.NilError(t, err)
+	assert.Equal(t, len(exec.Failed()), 4)
+	assert.Equal(t, len(exec.Package("pkg").Passed), 2)
+	assert.Equal(t, len(exec.Package("pkg2").Passed), 2)
+
+	// The events of each rerun must not be interleaved.
+	var pkgs []string
+	for _, event := range handler.events {
+		if len(pkgs) == 0 || pkgs[len(pkgs)-1] != event.Package {
+			pkgs = append(pkgs, event.Package)
+		}
+	}
+	sort.Strings(pkgs)
+	assert.DeepEqual(t, pkgs, []string{"pkg", "pkg2"})
+}
+
+// waitReader blocks reads from Reader until wait is closed.
+type waitReader struct {
+	io.Reader
+	wait chan struct{}
+}
+
+func (r *waitReader) Read(p []byte) (int, error) {
+	select {
+	case <-r.wait:
+		return r.Reader.Read(p)
+	case <-time.After(5 * time.Second):
+		return 0, fmt.Errorf("timeout waiting for the other rerun to start")
+	}
+}
+
+type eventRecorder struct {
+	noopHandler
+	events []testjson.TestEvent
+}
+
+func (r *eventRecorder) Event(event testjson.TestEvent, _ *testjson.Execution) error {
+	r.events = append(r.events, event)
+	return nil
+}
+
 func TestRerunFailed_AbortOnDataRace(t *testing.T) {
 	for _, tc := range []struct {
 		name            string
@@ -216,7 +530,7 @@ func dedentOutput(s string) string {
 
 func patchStartGoTestFn(f func(args []string) *proc) func() {
 	orig := startGoTestFn
-	startGoTestFn = func(_ context.Context, _ string, args []string) (*proc, error) {
+	startGoTestFn = func(_ context.Context, _ string, _ io.Reader, args []string) (*proc, error) {
 		return f(args), nil
 	}
 	return func() {
@@ -227,13 +541,17 @@ func patchStartGoTestFn(f func(args []string) *proc) func() {
 func newExecutionWithTwoFailures(t *testing.T) *testjson.Execution {
 	t.Helper()
 
-	out := `{"Package": "pkg", "Action": "run"}
+	return newExecution(t, `{"Package": "pkg", "Action": "run"}
 {"Package": "pkg", "Test": "TestOne", "Action": "run"}
 {"Package": "pkg", "Test": "TestOne", "Action": "fail"}
 {"Package": "pkg", "Test": "TestTwo", "Action": "run"}
 {"Package": "pkg", "Test": "TestTwo", "Action": "fail"}
 {"Package": "pkg", "Action": "fail"}
-`
+`)
+}
+
+func newExecution(t *testing.T, out string) *testjson.Execution {
+	t.Helper()
 	exec, err := testjson.ScanTestOutput(testjson.ScanConfig{
 		Stdout: strings.NewReader(out),
 		Stderr: strings.NewReader(""),
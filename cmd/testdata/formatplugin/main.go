@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// A format plugin used by tests. The first argument is the mode:
+//
+//	ok            print a line for each test and package result
+//	no-handshake  respond to the handshake with the wrong protocol
+//	crash         exit with a non-zero exit code after the first event
+//	print-close   the same as ok, and print a line when stdin is closed
+func main() {
+	mode := "ok"
+	if len(os.Args) > 1 {
+		mode = os.Args[1]
+	}
+
+	scanner := bufio.NewScanner(os.Stdin)
+	if !scanner.Scan() {
+		fmt.Fprintln(os.Stderr, "missing hello")
+		os.Exit(1)
+	}
+	var hello struct{ Protocol string }
+	if err := json.Unmarshal(scanner.Bytes(), &hello); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if mode == "no-handshake" {
+		fmt.Println("something else")
+		os.Exit(1)
+	}
+	fmt.Println(hello.Protocol)
+
+	for scanner.Scan() {
+		if mode == "crash" {
+			fmt.Fprintln(os.Stderr, "plugin crashed")
+			os.Exit(3)
+		}
+
+		var event struct {
+			Action        string
+			Package       string
+			Test          string
+			IsSubTest     bool
+			PackageCounts struct{ Total, Passed, Failed, Skipped int }
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		switch event.Action {
+		case "pass", "fail", "skip":
+		default:
+			continue
+		}
+		switch {
+		case event.Test == "":
+			c := event.PackageCounts
+			fmt.Printf("%s %s total=%d passed=%d failed=%d skipped=%d\n",
+				event.Action, event.Package, c.Total, c.Passed, c.Failed, c.Skipped)
+		case !event.IsSubTest:
+			fmt.Printf("%s %s\n", event.Action, event.Test)
+		}
+	}
+	if mode == "print-close" {
+		fmt.Println("stdin closed")
+	}
+}
@@ -0,0 +1,111 @@
+package junit2json
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/dnephin/pflag"
+	"gotest.tools/gotestsum/internal/junitxml"
+	"gotest.tools/gotestsum/internal/log"
+)
+
+// Run the command
+func Run(name string, args []string) error {
+	flags, opts := setupFlags(name)
+	switch err := flags.Parse(args); {
+	case err == pflag.ErrHelp:
+		return nil
+	case err != nil:
+		usage(os.Stderr, name, flags)
+		return err
+	}
+	opts.files = flags.Args()
+	opts.stdin = os.Stdin
+	opts.stdout = os.Stdout
+	return run(opts)
+}
+
+type options struct {
+	files []string
+	debug bool
+
+	// shims for testing
+	stdin  io.Reader
+	stdout io.Writer
+}
+
+func setupFlags(name string) (*pflag.FlagSet, *options) {
+	opts := &options{}
+	flags := pflag.NewFlagSet(name, pflag.ContinueOnError)
+	flags.SetInterspersed(false)
+	flags.Usage = func() {
+		usage(os.Stdout, name, flags)
+	}
+	flags.BoolVar(&opts.debug, "debug", false,
+		"enable debug logging")
+	return flags, opts
+}
+
+func usage(out io.Writer, name string, flags *pflag.FlagSet) {
+	fmt.Fprintf(out, `Usage:
+    %[1]s [flags] [FILE...]
+
+Read JUnit XML files and print the equivalent 'go test -json' output to stdout.
+If no files are given, a single JUnit XML document is read from stdin.
+
+Each testsuite is used as a package, and each testcase as a test in that
+package. The output can be used anywhere that accepts the output of
+'go test -json', for example to print a summary, or to write another report
+format:
+
+    gotestsum --junitfile-dir reports/ --raw-command -- %[1]s report.xml
+
+JUnit XML does not record the order or start time of tests, so the tests of
+each testsuite are given a start time that follows the previous test.
+
+Flags:
+`, name)
+	flags.SetOutput(out)
+	flags.PrintDefaults()
+}
+
+func run(opts *options) error {
+	if opts.debug {
+		log.SetLevel(log.DebugLevel)
+	}
+	if len(opts.files) == 0 {
+		return convert(opts.stdout, opts.stdin)
+	}
+	for _, name := range opts.files {
+		if err := convertFile(opts.stdout, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func convertFile(out io.Writer, name string) error {
+	fh, err := os.Open(name)
+	if err != nil {
+		return fmt.Errorf("failed to open JUnit XML file: %v", err)
+	}
+	defer func() {
+		if err := fh.Close(); err != nil {
+			log.Errorf("Failed to close file %v: %v", name, err)
+		}
+	}()
+	if err := convert(out, fh); err != nil {
+		return fmt.Errorf("%v: %w", name, err)
+	}
+	return nil
+}
+
+func convert(out io.Writer, in io.Reader) error {
+	events, err := junitxml.NewEventReader(in)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, events)
+	return err
+}
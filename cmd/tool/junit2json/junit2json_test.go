@@ -0,0 +1,48 @@
+package junit2json
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/env"
+	"gotest.tools/v3/golden"
+)
+
+func TestUsage_WithFlagsFromSetupFlags(t *testing.T) {
+	env.PatchAll(t, nil)
+
+	name := "gotestsum tool junit2json"
+	flags, _ := setupFlags(name)
+	buf := new(bytes.Buffer)
+	usage(buf, name, flags)
+
+	golden.Assert(t, buf.String(), "cmd-flags-help-text")
+}
+
+func TestRun(t *testing.T) {
+	stdout := new(bytes.Buffer)
+	opts := &options{
+		files:  []string{"testdata/report.xml", "testdata/report.xml"},
+		stdout: stdout,
+	}
+	err := run(opts)
+	assert.NilError(t, err)
+
+	expected, err := os.ReadFile("testdata/report.xml")
+	assert.NilError(t, err)
+	fromStdin := new(bytes.Buffer)
+	opts = &options{stdin: bytes.NewReader(expected), stdout: fromStdin}
+	assert.NilError(t, run(opts))
+
+	assert.Equal(t, stdout.String(), strings.Repeat(fromStdin.String(), 2))
+	golden.Assert(t, fromStdin.String(), "report-events.golden")
+}
+
+func TestRun_InvalidFile(t *testing.T) {
+	opts := &options{files: []string{"testdata/cmd-flags-help-text"}, stdout: new(bytes.Buffer)}
+	err := run(opts)
+	assert.ErrorContains(t, err, "testdata/cmd-flags-help-text: failed to read JUnit XML")
+}
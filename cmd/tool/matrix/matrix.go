@@ -13,6 +13,7 @@ import (
 	"time"
 
 	"github.com/dnephin/pflag"
+	"gotest.tools/gotestsum/internal/junitxml"
 	"gotest.tools/gotestsum/internal/log"
 	"gotest.tools/gotestsum/testjson"
 )
@@ -51,7 +52,8 @@ func setupFlags(name string) (*pflag.FlagSet, *options) {
 	flags.UintVar(&opts.numPartitions, "partitions", 0,
 		"number of parallel partitions to create in the test matrix")
 	flags.StringVar(&opts.timingFilesPattern, "timing-files", "",
-		"glob pattern to match files that contain test2json events, ex: ./logs/*.log")
+		"glob pattern to match files that contain test2json events, or JUnit XML files "+
+			"with a .xml extension, ex: ./logs/*.log")
 	flags.BoolVar(&opts.debug, "debug", false,
 		"enable debug logging")
 	return flags, opts
@@ -146,7 +148,14 @@ func parseEvent(reader io.Reader) (testjson.TestEvent, error) {
 func packageTiming(files []*os.File) (map[string][]time.Duration, error) {
 	timing := make(map[string][]time.Duration)
 	for _, fh := range files {
-		exec, err := testjson.ScanTestOutput(testjson.ScanConfig{Stdout: fh})
+		var in io.Reader = fh
+		if filepath.Ext(fh.Name()) == ".xml" {
+			var err error
+			if in, err = junitxml.NewEventReader(fh); err != nil {
+				return nil, fmt.Errorf("failed to read events from %v: %v", fh.Name(), err)
+			}
+		}
+		exec, err := testjson.ScanTestOutput(testjson.ScanConfig{Stdout: in})
 		if err != nil {
 			return nil, fmt.Errorf("failed to read events from %v: %v", fh.Name(), err)
 		}
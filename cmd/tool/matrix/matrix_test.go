@@ -255,3 +255,26 @@ func formatJSON(t *testing.T, v io.Reader) string {
 	assert.NilError(t, err)
 	return string(formatted)
 }
+
+func TestPackageTiming_JUnitXML(t *testing.T) {
+	dir := fs.NewDir(t, "timing-files",
+		fs.WithFile("report1.xml", `<testsuites>
+	<testsuite name="pkg0" tests="1" failures="0" errors="0" time="2.5">
+		<testcase classname="pkg0" name="TestOne" time="2.5"></testcase>
+	</testsuite>
+	<testsuite name="pkg1" tests="0" failures="0" errors="0" time="0.5"></testsuite>
+</testsuites>`),
+		fs.WithFile("report2.log", `{"Action":"pass","Package":"pkg0","Elapsed":1.5}`+"\n"))
+
+	files, err := readTimingReports(options{timingFilesPattern: dir.Join("report*")})
+	assert.NilError(t, err)
+	defer closeFiles(files)
+
+	timing, err := packageTiming(files)
+	assert.NilError(t, err)
+	expected := map[string][]time.Duration{
+		"pkg0": {2500 * time.Millisecond, 1500 * time.Millisecond},
+		"pkg1": {500 * time.Millisecond},
+	}
+	assert.DeepEqual(t, timing, expected)
+}
@@ -11,6 +11,7 @@ import (
 
 	"golang.org/x/tools/go/packages"
 	"gotest.tools/gotestsum/internal/log"
+	"gotest.tools/gotestsum/internal/testlocation"
 	"gotest.tools/gotestsum/testjson"
 )
 
@@ -20,7 +21,7 @@ func writeTestSkip(tcs []testjson.TestCase, skipStmt ast.Stmt) error {
 		Mode:       modeAll(),
 		Tests:      true,
 		Fset:       fset,
-		BuildFlags: buildFlags(),
+		BuildFlags: testlocation.BuildFlags(),
 	}
 	pkgNames, index := testNamesByPkgName(tcs)
 	pkgs, err := packages.Load(&cfg, pkgNames...)
@@ -32,7 +33,7 @@ func writeTestSkip(tcs []testjson.TestCase, skipStmt ast.Stmt) error {
 		if len(pkg.Errors) > 0 {
 			return errPkgLoad(pkg)
 		}
-		tcs, ok := index[normalizePkgName(pkg.PkgPath)]
+		tcs, ok := index[testlocation.NormalizePkgName(pkg.PkgPath)]
 		if !ok {
 			log.Debugf("skipping %v, no slow tests", pkg.PkgPath)
 			continue
@@ -53,14 +54,6 @@ func writeTestSkip(tcs []testjson.TestCase, skipStmt ast.Stmt) error {
 	return errTestCasesNotFound(index)
 }
 
-// normalizePkgName removes the _test suffix from a package name. External test
-// packages (those named package_test) may contain tests, but the test2json output
-// always uses the non-external package name. The _test suffix must be removed
-// so that any slow tests in an external test package can be found.
-func normalizePkgName(name string) string {
-	return strings.TrimSuffix(name, "_test")
-}
-
 func writeFile(path string, file *ast.File, fset *token.FileSet) error {
 	fh, err := os.Create(path)
 	if err != nil {
@@ -166,11 +159,3 @@ func modeAll() packages.LoadMode {
 	mode = mode | packages.NeedSyntax | packages.NeedTypesInfo
 	return mode
 }
-
-func buildFlags() []string {
-	flags := os.Getenv("GOFLAGS")
-	if len(flags) == 0 {
-		return nil
-	}
-	return strings.Split(os.Getenv("GOFLAGS"), " ")
-}
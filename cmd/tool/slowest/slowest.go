@@ -4,10 +4,12 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 	"time"
 
 	"github.com/dnephin/pflag"
 	"gotest.tools/gotestsum/internal/aggregate"
+	"gotest.tools/gotestsum/internal/junitxml"
 	"gotest.tools/gotestsum/internal/log"
 	"gotest.tools/gotestsum/testjson"
 )
@@ -33,7 +35,7 @@ func setupFlags(name string) (*pflag.FlagSet, *options) {
 		usage(os.Stdout, name, flags)
 	}
 	flags.StringVar(&opts.jsonfile, "jsonfile", os.Getenv("GOTESTSUM_JSONFILE"),
-		"path to test2json output, defaults to stdin")
+		"path to test2json output, or a JUnit XML file with a .xml extension, defaults to stdin")
 	flags.DurationVar(&opts.threshold, "threshold", 100*time.Millisecond,
 		"test cases with elapsed time greater than threshold are slow tests")
 	flags.IntVar(&opts.topN, "num", 0,
@@ -51,6 +53,8 @@ func usage(out io.Writer, name string, flags *pflag.FlagSet) {
 
 Read a json file and print or update tests which are slower than threshold.
 The json file may be created with 'gotestsum --jsonfile' or 'go test -json'.
+A JUnit XML file, such as one created by 'gotestsum --junitfile', may be used
+instead of a json file if the name of the file ends with .xml.
 If a TestCase appears more than once in the json file, it will only appear once
 in the output, and the median value of all the elapsed times will be used.
 
@@ -115,7 +119,14 @@ func run(opts *options) error {
 		}
 	}()
 
-	exec, err := testjson.ScanTestOutput(testjson.ScanConfig{Stdout: in})
+	var stdout io.Reader = in
+	if filepath.Ext(opts.jsonfile) == ".xml" {
+		if stdout, err = junitxml.NewEventReader(in); err != nil {
+			return err
+		}
+	}
+
+	exec, err := testjson.ScanTestOutput(testjson.ScanConfig{Stdout: stdout})
 	if err != nil {
 		return fmt.Errorf("failed to scan testjson: %v", err)
 	}
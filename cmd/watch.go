@@ -70,7 +70,7 @@ func runSingle(opts *options, dir string) (*testjson.Execution, error) {
 		return nil, err
 	}
 
-	goTestProc, err := startGoTestFn(ctx, dir, goTestCmdArgs(opts, rerunOpts{}))
+	goTestProc, err := startGoTestFn(ctx, dir, goTestStdin(opts), goTestCmdArgs(opts, rerunOpts{}))
 	if err != nil {
 		return nil, err
 	}
@@ -81,18 +81,19 @@ func runSingle(opts *options, dir string) (*testjson.Execution, error) {
 	}
 	defer handler.Close() //nolint:errcheck
 	cfg := testjson.ScanConfig{
-		Stdout:  goTestProc.stdout,
-		Stderr:  goTestProc.stderr,
-		Handler: handler,
-		Stop:    cancel,
+		Stdout:           goTestProc.stdout,
+		Stderr:           goTestProc.stderr,
+		Handler:          handler,
+		Stop:             cancel,
+		KeepPassedOutput: opts.keepPassedOutput(),
 	}
 	exec, err := testjson.ScanTestOutput(cfg)
 	handler.Flush()
 	if err != nil {
-		return exec, finishRun(opts, exec, err)
+		return exec, finishRun(opts, handler, exec, err)
 	}
 	err = goTestProc.cmd.Wait()
-	return exec, finishRun(opts, exec, err)
+	return exec, finishRun(opts, handler, exec, err)
 }
 
 func delveInitFile(exec *testjson.Execution) (string, func(), error) {
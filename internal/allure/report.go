@@ -0,0 +1,269 @@
+/*Package allure writes Allure (https://allurereport.org) result files from a testjson.Execution.
+ */
+package allure
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gotest.tools/gotestsum/internal/testresult"
+	"gotest.tools/gotestsum/testjson"
+)
+
+// Result is a single attempt of a test. It is written to a file named
+// <uuid>-result.json.
+type Result struct {
+	UUID          string        `json:"uuid"`
+	HistoryID     string        `json:"historyId"`
+	TestCaseID    string        `json:"testCaseId"`
+	FullName      string        `json:"fullName"`
+	Name          string        `json:"name"`
+	Status        string        `json:"status"`
+	StatusDetails StatusDetails `json:"statusDetails"`
+	Stage         string        `json:"stage"`
+	Start         int64         `json:"start"`
+	Stop          int64         `json:"stop"`
+	Labels        []Label       `json:"labels"`
+}
+
+// StatusDetails contains the message and output of a test which did not pass.
+type StatusDetails struct {
+	Message string `json:"message,omitempty"`
+	Trace   string `json:"trace,omitempty"`
+	// Flaky is true when the test passed after it failed in an earlier run.
+	Flaky bool `json:"flaky,omitempty"`
+}
+
+// Label is a name/value pair used by Allure to group and filter tests.
+type Label struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Container groups the results of a package. It is written to a file named
+// <uuid>-container.json.
+type Container struct {
+	UUID     string    `json:"uuid"`
+	Name     string    `json:"name"`
+	Children []string  `json:"children"`
+	Befores  []Fixture `json:"befores"`
+	Afters   []Fixture `json:"afters"`
+	Start    int64     `json:"start"`
+	Stop     int64     `json:"stop"`
+}
+
+// Fixture is the setup or teardown of a container. A failure in TestMain is
+// reported as a broken fixture of the package.
+type Fixture struct {
+	Name          string        `json:"name"`
+	Status        string        `json:"status"`
+	StatusDetails StatusDetails `json:"statusDetails"`
+	Stage         string        `json:"stage"`
+	Start         int64         `json:"start"`
+	Stop          int64         `json:"stop"`
+}
+
+const (
+	statusPassed  = "passed"
+	statusFailed  = "failed"
+	statusBroken  = "broken"
+	statusSkipped = "skipped"
+
+	stageFinished = "finished"
+)
+
+// Config used to write Allure results.
+type Config struct {
+	// newUUID is used by tests to create predictable file names.
+	newUUID func() string
+}
+
+// WriteDir writes a result file for each attempt of every test, and a
+// container file for each package, to dir. Existing files in dir are not
+// removed, so that the results of more than one run can be combined into a
+// single report.
+func WriteDir(dir string, exec *testjson.Execution, cfg Config) error {
+	if cfg.newUUID == nil {
+		cfg.newUUID = newUUID
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create Allure results directory: %v", err)
+	}
+
+	for _, name := range exec.Packages() {
+		pkg := exec.Package(name)
+		container := Container{
+			UUID:     cfg.newUUID(),
+			Name:     name,
+			Children: []string{},
+			Befores:  []Fixture{},
+			Afters:   []Fixture{},
+		}
+		if !pkg.Start.IsZero() {
+			container.Start = pkg.Start.UnixMilli()
+			container.Stop = pkg.Start.Add(pkg.Elapsed()).UnixMilli()
+		}
+		if pkg.TestMainFailed() {
+			container.Befores = append(container.Befores, testMainFixture(pkg, container))
+		}
+
+		for _, result := range packageResults(name, pkg, cfg) {
+			container.Children = append(container.Children, result.UUID)
+			if err := writeJSON(filepath.Join(dir, result.UUID+"-result.json"), result); err != nil {
+				return err
+			}
+		}
+		if err := writeJSON(filepath.Join(dir, container.UUID+"-container.json"), container); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func testMainFixture(pkg *testjson.Package, container Container) Fixture {
+	lines := pkg.OutputLines(testjson.TestCase{})
+	return Fixture{
+		Name:   "TestMain",
+		Status: statusBroken,
+		StatusDetails: StatusDetails{
+			Message: testresult.FirstLine(lines, "Failed"),
+			Trace:   strings.Join(lines, ""),
+		},
+		Stage: stageFinished,
+		Start: container.Start,
+		Stop:  container.Stop,
+	}
+}
+
+// packageResults returns a Result for every attempt of every test in the
+// package, sorted by RunID and the order the tests started. All the attempts
+// of a test have the same HistoryID, which Allure uses to show earlier
+// attempts as retries of the last one.
+func packageResults(name string, pkg *testjson.Package, cfg Config) []Result {
+	all := testresult.Attempts(pkg)
+	failed := make(map[testjson.TestName]bool)
+	results := make([]Result, 0, len(all))
+	for _, a := range all {
+		result := newResult(name, pkg, a, cfg)
+		if result.Status == statusPassed && failed[a.Test] {
+			result.StatusDetails.Flaky = true
+		}
+		if result.Status == statusFailed || result.Status == statusBroken {
+			failed[a.Test] = true
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// attemptStatus returns the status of the attempt. A failed test which did not
+// complete because of a panic or the test timeout is broken.
+func attemptStatus(pkg *testjson.Package, a testresult.Attempt) string {
+	switch {
+	case a.Action == testjson.ActionPass:
+		return statusPassed
+	case a.Action == testjson.ActionSkip:
+		return statusSkipped
+	case testresult.Incomplete(pkg, a.TestCase):
+		return statusBroken
+	default:
+		return statusFailed
+	}
+}
+
+func newResult(pkgName string, pkg *testjson.Package, a testresult.Attempt, cfg Config) Result {
+	fullName := pkgName + "." + a.Test.Name()
+	id := historyID(fullName)
+	result := Result{
+		UUID:       cfg.newUUID(),
+		HistoryID:  id,
+		TestCaseID: id,
+		FullName:   fullName,
+		Name:       a.Test.Name(),
+		Status:     attemptStatus(pkg, a),
+		Stage:      stageFinished,
+		Labels:     labels(pkgName, a.TestCase),
+	}
+	if !a.Time.IsZero() {
+		result.Start = a.Time.UnixMilli()
+		result.Stop = a.Time.Add(a.Elapsed).UnixMilli()
+	}
+	if result.Status != statusPassed {
+		lines := pkg.OutputLines(a.TestCase)
+		def := "Failed"
+		if result.Status == statusSkipped {
+			def = "Skipped"
+		}
+		result.StatusDetails.Message = testresult.FirstLine(lines, def)
+		result.StatusDetails.Trace = strings.Join(lines, "")
+	}
+	return result
+}
+
+// labels returns the default labels of a test, followed by a label for each
+// attribute emitted from T.Attr. An attribute with the same name as one of the
+// default labels (ex: suite) replaces the default label.
+func labels(pkgName string, tc testjson.TestCase) []Label {
+	root, sub := tc.Test.Split()
+	defaults := []Label{
+		{Name: "language", Value: "go"},
+		{Name: "framework", Value: "gotestsum"},
+		{Name: "package", Value: pkgName},
+		{Name: "testClass", Value: pkgName},
+		{Name: "testMethod", Value: tc.Test.Name()},
+		{Name: "suite", Value: pkgName},
+	}
+	if sub != "" {
+		defaults = append(defaults, Label{Name: "subSuite", Value: root})
+	}
+
+	result := make([]Label, 0, len(defaults)+len(tc.Attributes))
+	for _, label := range defaults {
+		if _, ok := tc.Attributes[label.Name]; !ok {
+			result = append(result, label)
+		}
+	}
+	keys := make([]string, 0, len(tc.Attributes))
+	for key := range tc.Attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		result = append(result, Label{Name: key, Value: tc.Attributes[key]})
+	}
+	return result
+}
+
+// historyID identifies a test across runs. Allure uses an md5 hash of the name
+// of the test.
+func historyID(fullName string) string {
+	sum := md5.Sum([]byte(fullName))
+	return hex.EncodeToString(sum[:])
+}
+
+// newUUID returns a random (version 4) UUID.
+func newUUID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+func writeJSON(path string, v any) error {
+	raw, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode Allure result: %v", err)
+	}
+	if err := os.WriteFile(path, append(raw, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to write Allure result: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package allure
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gotest.tools/gotestsum/internal/reporttest"
+	"gotest.tools/gotestsum/testjson"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/golden"
+)
+
+func TestWriteDir(t *testing.T) {
+	exec := reporttest.CreateExecution(t, testjson.ScanConfig{
+		Stdout: reporttest.ReadTestData(t, "go-test-json.out"),
+		Stderr: reporttest.ReadTestData(t, "go-test-json.err"),
+	})
+
+	dir := t.TempDir()
+	err := WriteDir(dir, exec, testConfig())
+	assert.NilError(t, err)
+	golden.Assert(t, readDir(t, dir), "allure-results.golden")
+}
+
+func TestPackageResults_RerunsShareHistoryID(t *testing.T) {
+	exec := reporttest.NewExecution(t, `{"Package": "pkg", "Test": "TestFlaky", "Action": "run"}
+{"Package": "pkg", "Test": "TestFlaky", "Action": "output", "Output": "    flaky_test.go:8: timed out\n"}
+{"Package": "pkg", "Test": "TestFlaky", "Action": "fail"}
+{"Package": "pkg", "Action": "fail"}
+`)
+	exec = reporttest.CreateExecution(t, testjson.ScanConfig{
+		RunID: 1,
+		Stdout: strings.NewReader(`{"Package": "pkg", "Test": "TestFlaky", "Action": "run"}
+{"Package": "pkg", "Test": "TestFlaky", "Action": "pass"}
+{"Package": "pkg", "Action": "pass"}
+`),
+		Execution: exec,
+	})
+
+	results := packageResults("pkg", exec.Package("pkg"), testConfig())
+	assert.Equal(t, len(results), 2)
+	assert.Equal(t, results[0].HistoryID, results[1].HistoryID)
+	assert.Equal(t, results[0].Status, statusFailed)
+	assert.Equal(t, results[0].StatusDetails.Message, "flaky_test.go:8: timed out")
+	assert.Equal(t, results[1].Status, statusPassed)
+	assert.Equal(t, results[1].StatusDetails.Flaky, true)
+}
+
+func TestLabels_AttributesReplaceDefaults(t *testing.T) {
+	tc := testjson.TestCase{
+		Test:       "TestOne/sub",
+		Attributes: map[string]string{"suite": "Checkout", "owner": "qa"},
+	}
+	expected := []Label{
+		{Name: "language", Value: "go"},
+		{Name: "framework", Value: "gotestsum"},
+		{Name: "package", Value: "example.com/pkg"},
+		{Name: "testClass", Value: "example.com/pkg"},
+		{Name: "testMethod", Value: "TestOne/sub"},
+		{Name: "subSuite", Value: "TestOne"},
+		{Name: "owner", Value: "qa"},
+		{Name: "suite", Value: "Checkout"},
+	}
+	assert.DeepEqual(t, labels("example.com/pkg", tc), expected)
+}
+
+func TestNewUUID(t *testing.T) {
+	id := newUUID()
+	assert.Equal(t, len(id), 36)
+	assert.Equal(t, id[14], byte('4'))
+	assert.Assert(t, id != newUUID())
+}
+
+func testConfig() Config {
+	var count int
+	return Config{
+		newUUID: func() string {
+			count++
+			return fmt.Sprintf("uuid-%03d", count)
+		},
+	}
+}
+
+// readDir returns the contents of all the files in dir, sorted by name, with
+// the name of the file before the contents.
+func readDir(t *testing.T, dir string) string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	assert.NilError(t, err)
+	var out strings.Builder
+	for _, entry := range entries {
+		raw, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		assert.NilError(t, err)
+		fmt.Fprintf(&out, "=== %v\n%s", entry.Name(), raw)
+	}
+	return out.String()
+}
@@ -0,0 +1,265 @@
+/*Package chrometrace creates a Chrome Trace Event file from a testjson.Execution.
+ */
+package chrometrace
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"gotest.tools/gotestsum/testjson"
+)
+
+// Write a timeline of the test run to out using the Chrome Trace Event
+// format. The file can be opened with https://ui.perfetto.dev or
+// chrome://tracing.
+//
+// Each package is a process, and each test is a slice on one of the threads
+// (tracks) of that process. Tests which run in parallel are placed on separate
+// tracks, and the time a test spent paused, waiting for other tests to
+// complete, is left as a gap in the slice. Tests from each rerun are placed on
+// their own tracks.
+func Write(out io.Writer, exec *testjson.Execution) error {
+	buf := bufio.NewWriter(out)
+	buf.WriteString("{\"traceEvents\":[\n")
+	for i, event := range generate(exec) {
+		raw, err := json.Marshal(event)
+		if err != nil {
+			return fmt.Errorf("failed to encode trace event: %v", err)
+		}
+		if i > 0 {
+			buf.WriteString(",\n")
+		}
+		buf.Write(raw)
+	}
+	buf.WriteString("\n],\n\"displayTimeUnit\":\"ms\"}\n")
+	if err := buf.Flush(); err != nil {
+		return fmt.Errorf("failed to write trace file: %v", err)
+	}
+	return nil
+}
+
+type event struct {
+	Name  string `json:"name"`
+	Cat   string `json:"cat,omitempty"`
+	Phase string `json:"ph"`
+	// Timestamp and Duration are in microseconds.
+	Timestamp int64          `json:"ts"`
+	Duration  int64          `json:"dur"`
+	PID       int            `json:"pid"`
+	TID       int            `json:"tid"`
+	Args      map[string]any `json:"args,omitempty"`
+}
+
+const (
+	phaseComplete = "X"
+	phaseMetadata = "M"
+)
+
+func metadata(name string, pid, tid int, args map[string]any) event {
+	return event{Name: name, Phase: phaseMetadata, PID: pid, TID: tid, Args: args}
+}
+
+type testCase struct {
+	testjson.TestCase
+	result string
+}
+
+func generate(exec *testjson.Execution) []event {
+	pkgs := make(map[string][]testCase)
+	var origin time.Time
+	for _, name := range exec.Packages() {
+		pkg := exec.Package(name)
+		for _, group := range []struct {
+			result string
+			tcs    []testjson.TestCase
+		}{
+			{result: "pass", tcs: pkg.Passed},
+			{result: "fail", tcs: pkg.Failed},
+			{result: "skip", tcs: pkg.Skipped},
+		} {
+			for _, tc := range group.tcs {
+				if tc.Time.IsZero() {
+					continue
+				}
+				if origin.IsZero() || tc.Time.Before(origin) {
+					origin = tc.Time
+				}
+				pkgs[name] = append(pkgs[name], testCase{TestCase: tc, result: group.result})
+			}
+		}
+	}
+
+	var events []event
+	for i, name := range exec.Packages() {
+		pid := i + 1
+		events = append(events,
+			metadata("process_name", pid, 0, map[string]any{"name": name}),
+			metadata("process_sort_index", pid, 0, map[string]any{"sort_index": pid}))
+
+		tracks := newTracks(pkgs[name])
+		for tid, track := range tracks {
+			events = append(events,
+				metadata("thread_name", pid, tid+1, map[string]any{"name": track.name}),
+				metadata("thread_sort_index", pid, tid+1, map[string]any{"sort_index": tid + 1}))
+		}
+		for tid, track := range tracks {
+			for _, tc := range track.tests {
+				events = append(events, testEvents(tc, origin, pid, tid+1)...)
+			}
+		}
+	}
+	return events
+}
+
+type track struct {
+	name  string
+	tests []testCase
+	// open is the stack of tests on this track which have not ended, as of
+	// the start time of the last test added to the track.
+	open []openTest
+}
+
+type openTest struct {
+	name string
+	end  time.Time
+}
+
+// add tc to the track if it fits. A test fits on a track if it starts after
+// the end of every other test on the track, except for the ancestors of the
+// test which contain the entire test.
+func (t *track) add(tc testCase) bool {
+	start, end := tc.Time, testEnd(tc)
+	open := t.open
+	for len(open) > 0 && !open[len(open)-1].end.After(start) {
+		open = open[:len(open)-1]
+	}
+	if len(open) > 0 {
+		parent := open[len(open)-1]
+		if !strings.HasPrefix(tc.Test.Name(), parent.name+"/") || end.After(parent.end) {
+			return false
+		}
+	}
+	t.open = append(open, openTest{name: tc.Test.Name(), end: end})
+	t.tests = append(t.tests, tc)
+	return true
+}
+
+// newTracks returns the tracks for the tests in a package. The tests from each
+// run are placed on separate tracks, and tests which overlap in time are placed
+// on separate tracks so that slices are never drawn on top of each other.
+func newTracks(tcs []testCase) []*track {
+	sort.Slice(tcs, func(i, j int) bool {
+		a, b := tcs[i], tcs[j]
+		switch {
+		case a.RunID != b.RunID:
+			return a.RunID < b.RunID
+		case !a.Time.Equal(b.Time):
+			return a.Time.Before(b.Time)
+		default:
+			return a.ID < b.ID
+		}
+	})
+
+	var tracks []*track
+	var runTracks []*track
+	runID := -1
+	for _, tc := range tcs {
+		if tc.RunID != runID {
+			runID = tc.RunID
+			runTracks = nil
+		}
+
+		if addToTracks(runTracks, tc) {
+			continue
+		}
+		t := &track{name: trackName(tc.RunID, len(runTracks))}
+		t.add(tc)
+		runTracks = append(runTracks, t)
+		tracks = append(tracks, t)
+	}
+	return tracks
+}
+
+func addToTracks(tracks []*track, tc testCase) bool {
+	for _, t := range tracks {
+		if t.add(tc) {
+			return true
+		}
+	}
+	return false
+}
+
+func trackName(runID int, index int) string {
+	name := "tests"
+	if runID > 0 {
+		name = fmt.Sprintf("rerun %d", runID)
+	}
+	if index > 0 {
+		name += fmt.Sprintf(" (%d)", index+1)
+	}
+	return name
+}
+
+type span struct {
+	start    time.Time
+	duration time.Duration
+}
+
+// spans returns the periods of time when the test was running. TestCase.Elapsed
+// does not include the time the test was paused, so the last span is the
+// remainder of Elapsed after the time the test ran before each pause.
+func spans(tc testCase) []span {
+	var result []span
+	start, remaining := tc.Time, tc.Elapsed
+	if remaining < 0 {
+		remaining = 0
+	}
+	for _, pause := range tc.Pauses {
+		d := pause.Start.Sub(start)
+		switch {
+		case d < 0:
+			d = 0
+		case d > remaining:
+			d = remaining
+		}
+		result = append(result, span{start: start, duration: d})
+		remaining -= d
+		if pause.End.IsZero() {
+			return result
+		}
+		start = pause.End
+	}
+	return append(result, span{start: start, duration: remaining})
+}
+
+func testEnd(tc testCase) time.Time {
+	s := spans(tc)
+	last := s[len(s)-1]
+	return last.start.Add(last.duration)
+}
+
+func testEvents(tc testCase, origin time.Time, pid, tid int) []event {
+	var events []event
+	for _, s := range spans(tc) {
+		events = append(events, event{
+			Name:      tc.Test.Name(),
+			Cat:       tc.result,
+			Phase:     phaseComplete,
+			Timestamp: s.start.Sub(origin).Microseconds(),
+			Duration:  s.duration.Microseconds(),
+			PID:       pid,
+			TID:       tid,
+			Args: map[string]any{
+				"result":  tc.result,
+				"elapsed": testjson.FormatDurationAsSeconds(tc.Elapsed, 3),
+				"runID":   tc.RunID,
+			},
+		})
+	}
+	return events
+}
@@ -0,0 +1,80 @@
+package chrometrace
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"gotest.tools/gotestsum/internal/reporttest"
+	"gotest.tools/gotestsum/testjson"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/golden"
+)
+
+func TestWrite(t *testing.T) {
+	out := new(bytes.Buffer)
+	exec := reporttest.CreateExecution(t, testjson.ScanConfig{
+		Stdout: reporttest.ReadTestData(t, "go-test-json.out"),
+		Stderr: reporttest.ReadTestData(t, "go-test-json.err"),
+	})
+
+	err := Write(out, exec)
+	assert.NilError(t, err)
+	assert.Assert(t, json.Valid(out.Bytes()))
+	golden.Assert(t, out.String(), "trace.golden")
+}
+
+func TestNewTracks(t *testing.T) {
+	start := time.Date(2022, 6, 19, 13, 45, 0, 0, time.UTC)
+	newTestCase := func(name string, runID int, offset, elapsed time.Duration) testCase {
+		return testCase{TestCase: testjson.TestCase{
+			Test:    testjson.TestName(name),
+			RunID:   runID,
+			Time:    start.Add(offset),
+			Elapsed: elapsed,
+		}}
+	}
+	tcs := []testCase{
+		newTestCase("TestParent", 0, 0, 10*time.Millisecond),
+		newTestCase("TestParent/sub", 0, time.Millisecond, 2*time.Millisecond),
+		// runs in parallel with TestParent
+		newTestCase("TestParallel", 0, 2*time.Millisecond, 4*time.Millisecond),
+		newTestCase("TestAfter", 0, 10*time.Millisecond, time.Millisecond),
+		newTestCase("TestParallel", 1, 20*time.Millisecond, time.Millisecond),
+	}
+
+	var actual [][]string
+	for _, track := range newTracks(tcs) {
+		names := []string{track.name}
+		for _, tc := range track.tests {
+			names = append(names, tc.Test.Name())
+		}
+		actual = append(actual, names)
+	}
+	expected := [][]string{
+		{"tests", "TestParent", "TestParent/sub", "TestAfter"},
+		{"tests (2)", "TestParallel"},
+		{"rerun 1", "TestParallel"},
+	}
+	assert.DeepEqual(t, actual, expected)
+}
+
+func TestSpans(t *testing.T) {
+	start := time.Date(2022, 6, 19, 13, 45, 0, 0, time.UTC)
+	tc := testCase{TestCase: testjson.TestCase{
+		Time:    start,
+		Elapsed: 5 * time.Millisecond,
+		Pauses: []testjson.Pause{
+			{Start: start.Add(time.Millisecond), End: start.Add(10 * time.Millisecond)},
+		},
+	}}
+
+	expected := []span{
+		{start: start, duration: time.Millisecond},
+		{start: start.Add(10 * time.Millisecond), duration: 4 * time.Millisecond},
+	}
+	assert.DeepEqual(t, spans(tc), expected, cmp.AllowUnexported(span{}))
+	assert.Equal(t, testEnd(tc), start.Add(14*time.Millisecond))
+}
@@ -0,0 +1,197 @@
+/*Package ctrf creates a CTRF (https://ctrf.io) JSON report from a testjson.Execution.
+ */
+package ctrf
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"gotest.tools/gotestsum/internal/testresult"
+	"gotest.tools/gotestsum/testjson"
+)
+
+// Report is the root of a CTRF document.
+type Report struct {
+	ReportFormat string  `json:"reportFormat"`
+	SpecVersion  string  `json:"specVersion"`
+	Results      Results `json:"results"`
+}
+
+// Results of the test run.
+type Results struct {
+	Tool    Tool    `json:"tool"`
+	Summary Summary `json:"summary"`
+	Tests   []Test  `json:"tests"`
+}
+
+// Tool that ran the tests.
+type Tool struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+// Summary of the results. Start and Stop are milliseconds since the epoch.
+type Summary struct {
+	Tests   int   `json:"tests"`
+	Passed  int   `json:"passed"`
+	Failed  int   `json:"failed"`
+	Pending int   `json:"pending"`
+	Skipped int   `json:"skipped"`
+	Other   int   `json:"other"`
+	Start   int64 `json:"start"`
+	Stop    int64 `json:"stop"`
+}
+
+// Test is the result of a single test. When a test was rerun by --rerun-fails
+// all the attempts are reported by a single Test, with the result of the
+// last attempt.
+type Test struct {
+	Name     string `json:"name"`
+	Status   string `json:"status"`
+	Duration int64  `json:"duration"`
+	Start    int64  `json:"start,omitempty"`
+	Stop     int64  `json:"stop,omitempty"`
+	Suite    string `json:"suite"`
+	Message  string `json:"message,omitempty"`
+	Trace    string `json:"trace,omitempty"`
+	Type     string `json:"type"`
+	Retries  int    `json:"retries"`
+	Flaky    bool   `json:"flaky"`
+	// Extra contains the attributes emitted from T.Attr.
+	Extra map[string]string `json:"extra,omitempty"`
+}
+
+const (
+	statusPassed  = "passed"
+	statusFailed  = "failed"
+	statusSkipped = "skipped"
+)
+
+// Config used to write a CTRF report.
+type Config struct {
+	// ToolVersion is the version of gotestsum.
+	ToolVersion string
+	// These are used for tests to have a consistent start and stop time
+	customTimestamp time.Time
+	customElapsed   time.Duration
+}
+
+// Write creates a CTRF JSON document and writes it to out.
+func Write(out io.Writer, exec *testjson.Execution, cfg Config) error {
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(generate(exec, cfg)); err != nil {
+		return fmt.Errorf("failed to write CTRF JSON: %v", err)
+	}
+	return nil
+}
+
+func generate(exec *testjson.Execution, cfg Config) Report {
+	start, elapsed := exec.Started(), exec.Elapsed()
+	if !cfg.customTimestamp.IsZero() {
+		start, elapsed = cfg.customTimestamp, cfg.customElapsed
+	}
+	report := Report{
+		ReportFormat: "CTRF",
+		SpecVersion:  "0.0.0",
+		Results: Results{
+			Tool: Tool{Name: "gotestsum", Version: cfg.ToolVersion},
+			Summary: Summary{
+				Start: start.UnixMilli(),
+				Stop:  start.Add(elapsed).UnixMilli(),
+			},
+			Tests: []Test{},
+		},
+	}
+
+	for _, name := range exec.Packages() {
+		pkg := exec.Package(name)
+		if pkg.TestMainFailed() {
+			report.Results.Tests = append(report.Results.Tests, Test{
+				Name:    "TestMain",
+				Status:  statusFailed,
+				Suite:   name,
+				Message: "Failed",
+				Trace:   strings.Join(pkg.OutputLines(testjson.TestCase{}), ""),
+				Type:    "unit",
+			})
+		}
+		for _, attempts := range testresult.ByName(pkg) {
+			report.Results.Tests = append(report.Results.Tests, newTest(pkg, attempts))
+		}
+	}
+
+	summary := &report.Results.Summary
+	for _, test := range report.Results.Tests {
+		summary.Tests++
+		switch test.Status {
+		case statusPassed:
+			summary.Passed++
+		case statusFailed:
+			summary.Failed++
+		case statusSkipped:
+			summary.Skipped++
+		}
+	}
+	return report
+}
+
+func newTest(pkg *testjson.Package, attempts []testresult.Attempt) Test {
+	last := attempts[len(attempts)-1]
+	test := Test{
+		Name:     last.Test.Name(),
+		Status:   lastRunStatus(attempts),
+		Duration: durationMillis(last.Elapsed),
+		Suite:    last.Package,
+		Type:     "unit",
+		Retries:  last.RunID,
+		Extra:    last.Attributes,
+	}
+	if !last.Time.IsZero() {
+		test.Start = last.Time.UnixMilli()
+		test.Stop = last.Time.Add(time.Duration(test.Duration) * time.Millisecond).UnixMilli()
+	}
+
+	for _, a := range attempts {
+		if a.Action != testjson.ActionFail {
+			continue
+		}
+		if test.Status == statusPassed {
+			test.Flaky = true
+		}
+		lines := pkg.OutputLines(a.TestCase)
+		test.Message = testresult.FirstLine(lines, "Failed")
+		test.Trace = strings.Join(lines, "")
+	}
+	return test
+}
+
+// lastRunStatus returns the status of the test in its last run. A run may
+// include more than one attempt when go test -count is used, so the test is
+// failed if any of the attempts in that run failed.
+func lastRunStatus(attempts []testresult.Attempt) string {
+	lastRunID := attempts[len(attempts)-1].RunID
+	status := statusSkipped
+	for _, a := range attempts {
+		if a.RunID != lastRunID {
+			continue
+		}
+		switch a.Action {
+		case testjson.ActionFail:
+			return statusFailed
+		case testjson.ActionPass:
+			status = statusPassed
+		}
+	}
+	return status
+}
+
+func durationMillis(d time.Duration) int64 {
+	if d < 0 {
+		return 0
+	}
+	return d.Milliseconds()
+}
@@ -0,0 +1,118 @@
+package ctrf
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"gotest.tools/gotestsum/internal/reporttest"
+	"gotest.tools/gotestsum/testjson"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/golden"
+)
+
+func TestWrite(t *testing.T) {
+	out := new(bytes.Buffer)
+	exec := reporttest.CreateExecution(t, testjson.ScanConfig{
+		Stdout: reporttest.ReadTestData(t, "go-test-json.out"),
+		Stderr: reporttest.ReadTestData(t, "go-test-json.err"),
+	})
+
+	err := Write(out, exec, testConfig())
+	assert.NilError(t, err)
+	golden.Assert(t, out.String(), "ctrf.golden")
+}
+
+func TestGenerate_RerunsAreRetries(t *testing.T) {
+	exec := reporttest.NewExecution(t, `{"Package": "pkg", "Test": "TestFlaky", "Action": "run"}
+{"Package": "pkg", "Test": "TestFlaky", "Action": "output", "Output": "    flaky_test.go:8: timed out\n"}
+{"Package": "pkg", "Test": "TestFlaky", "Action": "fail"}
+{"Package": "pkg", "Test": "TestBroken", "Action": "run"}
+{"Package": "pkg", "Test": "TestBroken", "Action": "fail"}
+{"Package": "pkg", "Action": "fail"}
+`)
+	exec = reporttest.CreateExecution(t, testjson.ScanConfig{
+		RunID: 1,
+		Stdout: strings.NewReader(`{"Package": "pkg", "Test": "TestFlaky", "Action": "run"}
+{"Package": "pkg", "Test": "TestFlaky", "Action": "pass"}
+{"Package": "pkg", "Test": "TestBroken", "Action": "run"}
+{"Package": "pkg", "Test": "TestBroken", "Action": "fail"}
+{"Package": "pkg", "Action": "fail"}
+`),
+		Execution: exec,
+	})
+
+	results := generate(exec, testConfig()).Results
+	assert.DeepEqual(t, results.Summary, Summary{
+		Tests:  2,
+		Passed: 1,
+		Failed: 1,
+		Start:  testConfig().customTimestamp.UnixMilli(),
+		Stop:   testConfig().customTimestamp.Add(testConfig().customElapsed).UnixMilli(),
+	})
+
+	flaky := results.Tests[0]
+	assert.Equal(t, flaky.Name, "TestFlaky")
+	assert.Equal(t, flaky.Status, statusPassed)
+	assert.Equal(t, flaky.Retries, 1)
+	assert.Equal(t, flaky.Flaky, true)
+	assert.Equal(t, flaky.Message, "flaky_test.go:8: timed out")
+
+	broken := results.Tests[1]
+	assert.Equal(t, broken.Name, "TestBroken")
+	assert.Equal(t, broken.Status, statusFailed)
+	assert.Equal(t, broken.Retries, 1)
+	assert.Equal(t, broken.Flaky, false)
+}
+
+func TestGenerate_FailedWhenAnyAttemptOfTheLastRunFailed(t *testing.T) {
+	// go test -count=2 runs the test twice with the same RunID
+	exec := reporttest.NewExecution(t, `{"Package": "pkg", "Test": "TestOne", "Action": "run"}
+{"Package": "pkg", "Test": "TestOne", "Action": "fail"}
+{"Package": "pkg", "Test": "TestOne", "Action": "run"}
+{"Package": "pkg", "Test": "TestOne", "Action": "pass"}
+{"Package": "pkg", "Action": "fail"}
+`)
+
+	tests := generate(exec, testConfig()).Results.Tests
+	assert.Equal(t, len(tests), 1)
+	assert.Equal(t, tests[0].Status, statusFailed)
+	assert.Equal(t, tests[0].Retries, 0)
+	assert.Equal(t, tests[0].Flaky, false)
+}
+
+func TestGenerate_AttributesAreExtra(t *testing.T) {
+	exec := reporttest.NewExecution(t, `{"Package": "pkg", "Test": "TestOne", "Action": "run"}
+{"Package": "pkg", "Test": "TestOne", "Action": "attr", "Key": "owner", "Value": "storage"}
+{"Package": "pkg", "Test": "TestOne", "Action": "pass"}
+{"Package": "pkg", "Action": "pass"}
+`)
+
+	tests := generate(exec, testConfig()).Results.Tests
+	assert.DeepEqual(t, tests[0].Extra, map[string]string{"owner": "storage"})
+}
+
+func TestGenerate_TestMainFailed(t *testing.T) {
+	exec := reporttest.NewExecution(t, `{"Package": "pkg", "Action": "output", "Output": "setup failed\n"}
+{"Package": "pkg", "Action": "fail"}
+`)
+
+	tests := generate(exec, testConfig()).Results.Tests
+	assert.DeepEqual(t, tests, []Test{{
+		Name:    "TestMain",
+		Status:  statusFailed,
+		Suite:   "pkg",
+		Message: "Failed",
+		Trace:   "setup failed\n",
+		Type:    "unit",
+	}})
+}
+
+func testConfig() Config {
+	return Config{
+		ToolVersion:     "v7.7.7",
+		customTimestamp: time.Date(2022, 6, 19, 13, 45, 0, 0, time.UTC),
+		customElapsed:   2 * time.Second,
+	}
+}
@@ -10,8 +10,8 @@ import (
 	"io"
 	"os"
 
-	"golang.org/x/sys/unix"
 	"gotest.tools/gotestsum/internal/log"
+	"gotest.tools/gotestsum/internal/tty"
 )
 
 type terminal struct {
@@ -33,7 +33,7 @@ func (r *terminal) Start() {
 		return
 	}
 	fd := int(os.Stdin.Fd())
-	reset, err := enableNonBlockingRead(fd)
+	reset, err := tty.SetInputMode(fd, 1, 0)
 	if err != nil {
 		log.Warnf("no terminal input -- keyboard shortcuts disabled: %v", err)
 		return
@@ -41,29 +41,6 @@ func (r *terminal) Start() {
 	r.reset = reset
 }
 
-func enableNonBlockingRead(fd int) (func(), error) {
-	term, err := unix.IoctlGetTermios(fd, tcGet)
-	if err != nil {
-		return nil, err
-	}
-
-	state := *term
-	reset := func() {
-		if err := unix.IoctlSetTermios(fd, tcSet, &state); err != nil {
-			log.Debugf("failed to reset fd %d: %v", fd, err)
-		}
-	}
-
-	term.Lflag &^= unix.ECHO | unix.ICANON
-	term.Cc[unix.VMIN] = 1
-	term.Cc[unix.VTIME] = 0
-	if err := unix.IoctlSetTermios(fd, tcSet, term); err != nil {
-		reset()
-		return nil, err
-	}
-	return reset, nil
-}
-
 var stdin io.Reader = os.Stdin
 
 // Monitor the terminal for key presses. If the key press is associated with an
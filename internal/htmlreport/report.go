@@ -0,0 +1,185 @@
+/*Package htmlreport creates a self-contained HTML report from a testjson.Execution.
+ */
+package htmlreport
+
+import (
+	_ "embed"
+	"fmt"
+	"html/template"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"gotest.tools/gotestsum/testjson"
+)
+
+// Config used to write an HTML report.
+type Config struct {
+	// Title of the report. Defaults to "Test report".
+	Title string
+	// This is used for tests to have a consistent timestamp
+	customTimestamp string
+}
+
+//go:embed report.html.tmpl
+var reportTemplate string
+
+var tmpl = template.Must(template.New("report").Parse(reportTemplate))
+
+// Write creates an HTML document and writes it to out.
+func Write(out io.Writer, exec *testjson.Execution, cfg Config) error {
+	if err := tmpl.Execute(out, generate(exec, cfg)); err != nil {
+		return fmt.Errorf("failed to write HTML report: %v", err)
+	}
+	return nil
+}
+
+type report struct {
+	Title     string
+	Timestamp string
+	Total     int
+	Passed    int
+	Failed    int
+	Skipped   int
+	Errors    []string
+	Elapsed   string
+	Packages  []pkgReport
+}
+
+type pkgReport struct {
+	Name     string
+	Result   string
+	Elapsed  string
+	Coverage string
+	// Output is the package output, included when the package failed without
+	// any test failures (ex: a TestMain or init failure).
+	Output string
+	Runs   []runReport
+}
+
+// runReport is the tree of tests from one run of the package. There is one
+// run for the initial run of the package, and one for each rerun from
+// --rerun-fails.
+type runReport struct {
+	RunID int
+	Tests []*testReport
+}
+
+type testReport struct {
+	Name     string
+	FullName string
+	Result   string
+	Elapsed  string
+	Output   string
+	Subtests []*testReport
+}
+
+func generate(exec *testjson.Execution, cfg Config) report {
+	r := report{
+		Title:     cfg.Title,
+		Timestamp: cfg.customTimestamp,
+		Total:     exec.Total(),
+		Failed:    len(exec.Failed()),
+		Skipped:   len(exec.Skipped()),
+		Errors:    exec.Errors(),
+		Elapsed:   testjson.FormatDurationAsSeconds(exec.Elapsed(), 3),
+	}
+	if r.Title == "" {
+		r.Title = "Test report"
+	}
+	if r.Timestamp == "" {
+		r.Timestamp = exec.Started().Format(time.RFC3339)
+	}
+
+	for _, name := range exec.Packages() {
+		pkg := exec.Package(name)
+		r.Passed += len(pkg.Passed)
+		r.Packages = append(r.Packages, newPkgReport(name, pkg))
+	}
+	return r
+}
+
+func newPkgReport(name string, pkg *testjson.Package) pkgReport {
+	result := pkgReport{
+		Name:     name,
+		Result:   pkgResult(pkg),
+		Elapsed:  testjson.FormatDurationAsSeconds(pkg.Elapsed(), 3),
+		Coverage: strings.TrimPrefix(pkg.Coverage(), "coverage: "),
+	}
+	if pkg.TestMainFailed() {
+		result.Output = strings.Join(pkg.OutputLines(testjson.TestCase{}), "")
+	}
+
+	results := make(map[int]string)
+	byRunID := make(map[int][]testjson.TestCase)
+	for _, group := range []struct {
+		result string
+		tcs    []testjson.TestCase
+	}{
+		{result: "pass", tcs: pkg.Passed},
+		{result: "fail", tcs: pkg.Failed},
+		{result: "skip", tcs: pkg.Skipped},
+	} {
+		for _, tc := range group.tcs {
+			results[tc.ID] = group.result
+			byRunID[tc.RunID] = append(byRunID[tc.RunID], tc)
+		}
+	}
+	runIDs := make([]int, 0, len(byRunID))
+	for runID := range byRunID {
+		runIDs = append(runIDs, runID)
+	}
+	sort.Ints(runIDs)
+
+	for _, runID := range runIDs {
+		result.Runs = append(result.Runs, runReport{
+			RunID: runID,
+			Tests: testTree(pkg, byRunID[runID], results),
+		})
+	}
+	return result
+}
+
+func pkgResult(pkg *testjson.Package) string {
+	switch {
+	case pkg.Result() == testjson.ActionFail:
+		return "fail"
+	case pkg.Total == 0:
+		return "skip"
+	default:
+		return "pass"
+	}
+}
+
+// testTree returns the root tests from tcs, with subtests nested under their
+// parent test. results maps the TestCase.ID to the result of the test.
+func testTree(pkg *testjson.Package, tcs []testjson.TestCase, results map[int]string) []*testReport {
+	// Sort by ID so that every parent test is added to the tree before its
+	// subtests, and tests are listed in the order they started.
+	sort.Slice(tcs, func(i, j int) bool {
+		return tcs[i].ID < tcs[j].ID
+	})
+
+	var roots []*testReport
+	byName := make(map[string]*testReport)
+	for _, tc := range tcs {
+		node := &testReport{
+			Name:     tc.Test.Name(),
+			FullName: tc.Test.Name(),
+			Result:   results[tc.ID],
+			Elapsed:  testjson.FormatDurationAsSeconds(tc.Elapsed, 3),
+			Output:   strings.Join(pkg.OutputLines(tc), ""),
+		}
+		byName[tc.Test.Name()] = node
+
+		parent, ok := byName[tc.Test.Parent()]
+		if !ok {
+			roots = append(roots, node)
+			continue
+		}
+		node.Name = strings.TrimPrefix(node.Name, tc.Test.Parent()+"/")
+		parent.Subtests = append(parent.Subtests, node)
+	}
+	return roots
+}
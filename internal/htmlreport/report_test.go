@@ -0,0 +1,60 @@
+package htmlreport
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"gotest.tools/gotestsum/internal/reporttest"
+	"gotest.tools/gotestsum/testjson"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/golden"
+)
+
+func TestWrite(t *testing.T) {
+	out := new(bytes.Buffer)
+	exec := reporttest.CreateExecution(t, testjson.ScanConfig{
+		Stdout: reporttest.ReadTestData(t, "go-test-json.out"),
+		Stderr: reporttest.ReadTestData(t, "go-test-json.err"),
+	})
+
+	err := Write(out, exec, Config{
+		customTimestamp: new(time.Time).Format(time.RFC3339),
+	})
+	assert.NilError(t, err)
+	golden.Assert(t, out.String(), "htmlreport.golden")
+}
+
+func TestWrite_WithPassedTestOutput(t *testing.T) {
+	out := new(bytes.Buffer)
+	exec := reporttest.CreateExecution(t, testjson.ScanConfig{
+		Stdout:           reporttest.ReadTestData(t, "go-test-json.out"),
+		Stderr:           reporttest.ReadTestData(t, "go-test-json.err"),
+		KeepPassedOutput: true,
+	})
+
+	err := Write(out, exec, Config{
+		customTimestamp: new(time.Time).Format(time.RFC3339),
+	})
+	assert.NilError(t, err)
+	golden.Assert(t, out.String(), "htmlreport-passed-output.golden")
+}
+
+func TestWrite_WithCoverageAndReruns(t *testing.T) {
+	exec := reporttest.CreateExecution(t, testjson.ScanConfig{
+		Stdout: reporttest.ReadTestData(t, "go-test-json-with-cover.out"),
+	})
+	exec = reporttest.CreateExecution(t, testjson.ScanConfig{
+		RunID:     1,
+		Stdout:    reporttest.ReadTestData(t, "go-test-json-with-cover.out"),
+		Execution: exec,
+	})
+
+	out := new(bytes.Buffer)
+	err := Write(out, exec, Config{
+		Title:           "Coverage",
+		customTimestamp: new(time.Time).Format(time.RFC3339),
+	})
+	assert.NilError(t, err)
+	golden.Assert(t, out.String(), "htmlreport-coverage-reruns.golden")
+}
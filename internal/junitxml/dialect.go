@@ -0,0 +1,174 @@
+package junitxml
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gotest.tools/gotestsum/testjson"
+)
+
+// Dialect adjusts the JUnit XML document for a known consumer of the
+// document.
+type Dialect string
+
+const (
+	// DialectDefault is the document written when no dialect is selected.
+	DialectDefault Dialect = "default"
+	// DialectJenkins adds the hostname, id, and package of every testsuite,
+	// and uses the root test of a subtest as the class of the testcase, so that
+	// subtests are grouped by their root test.
+	DialectJenkins Dialect = "jenkins"
+	// DialectGitLab omits the properties of testcases, which are not shown by
+	// GitLab.
+	DialectGitLab Dialect = "gitlab"
+	// DialectAzure adds the hostname, id, and package of every testsuite, omits
+	// the properties of testcases, and writes a short message for skipped
+	// tests.
+	DialectAzure Dialect = "azure"
+	// DialectAnt writes a document that validates against the strict Ant JUnit
+	// XML schema. The schema does not support skipped tests, so skipped tests
+	// are omitted.
+	DialectAnt Dialect = "ant"
+)
+
+// Dialects is the list of all the dialects.
+var Dialects = []Dialect{DialectDefault, DialectJenkins, DialectGitLab, DialectAzure, DialectAnt}
+
+// ParseDialect returns the Dialect with name, or an error if there is no
+// dialect with that name.
+func ParseDialect(name string) (Dialect, error) {
+	if name == "" {
+		return DialectDefault, nil
+	}
+	for _, d := range Dialects {
+		if string(d) == name {
+			return d, nil
+		}
+	}
+	return "", fmt.Errorf("invalid JUnit dialect %q, must be one of: %v", name, dialectNames())
+}
+
+func dialectNames() string {
+	names := make([]string, 0, len(Dialects))
+	for _, d := range Dialects {
+		names = append(names, string(d))
+	}
+	return strings.Join(names, ", ")
+}
+
+type dialectOptions struct {
+	// skippedMessage is true if the output of a skipped test is used as the
+	// message of the skipped element.
+	skippedMessage bool
+	// suiteIdentity adds the hostname, id, and package to each testsuite.
+	suiteIdentity bool
+	// subtestClassname adds the name of the root test to the classname of
+	// subtests, and removes it from the name.
+	subtestClassname bool
+	// testCaseProperties allows properties on testcases.
+	testCaseProperties bool
+	// strict removes all the elements and attributes which are not part of
+	// the Ant JUnit XML schema, and adds all the required ones.
+	strict bool
+}
+
+var dialects = map[Dialect]dialectOptions{
+	DialectDefault: {skippedMessage: true, testCaseProperties: true},
+	DialectJenkins: {skippedMessage: true, testCaseProperties: true, subtestClassname: true, suiteIdentity: true},
+	DialectGitLab:  {skippedMessage: true},
+	DialectAzure:   {suiteIdentity: true},
+	DialectAnt:     {suiteIdentity: true, strict: true},
+}
+
+func applyDialect(suites *JUnitTestSuites, cfg Config) {
+	opts, ok := dialects[cfg.Dialect]
+	if !ok {
+		opts = dialects[DialectDefault]
+	}
+	hostname := cfg.customHostname
+	if opts.suiteIdentity && hostname == "" {
+		hostname, _ = os.Hostname()
+	}
+
+	for i := range suites.Suites {
+		suite := &suites.Suites[i]
+		if opts.suiteIdentity {
+			suite.Hostname = hostname
+			suite.ID = strconv.Itoa(i)
+			suite.Package = suite.Name
+		}
+
+		cases := suite.TestCases[:0]
+		for _, tc := range suite.TestCases {
+			if opts.strict && tc.SkipMessage != nil {
+				continue
+			}
+			if !opts.skippedMessage && tc.SkipMessage != nil {
+				tc.SkipMessage.Message = "Skipped"
+			}
+			if !opts.testCaseProperties {
+				tc.Properties = nil
+			}
+			if opts.subtestClassname {
+				if root, sub := testjson.TestName(tc.Name).Split(); sub != "" {
+					tc.Classname += "." + root
+					tc.Name = sub
+				}
+			}
+			if opts.strict {
+				strictTestCase(&tc)
+			}
+			cases = append(cases, tc)
+		}
+		suite.TestCases = cases
+
+		if opts.strict {
+			// skipped tests were removed, so every total is counted again.
+			suites.Tests -= suite.Tests
+			suites.Failures -= suite.Failures
+			suites.Errors -= suite.Errors
+			suite.Tests, suite.Failures, suite.Errors, suite.Skipped = 0, 0, 0, 0
+			countTestCases(suite)
+			suites.Tests += suite.Tests
+			suites.Failures += suite.Failures
+			suites.Errors += suite.Errors
+			suite.Timestamp = strictTimestamp(suite.Timestamp)
+			if suite.SystemOut == nil {
+				suite.SystemOut = &JUnitOutput{}
+			}
+			if suite.SystemErr == nil {
+				suite.SystemErr = &JUnitOutput{}
+			}
+		}
+	}
+	suites.strict = opts.strict
+}
+
+// strictTestCase removes the elements and attributes of a testcase which are
+// not part of the Ant JUnit XML schema.
+func strictTestCase(tc *JUnitTestCase) {
+	tc.File, tc.Line = "", 0
+	tc.Properties = nil
+	tc.SystemOut = ""
+	tc.FlakyFailures = nil
+	tc.RerunFailures = nil
+	if tc.Failure != nil {
+		tc.Failure.File, tc.Failure.Line = "", 0
+		if tc.Failure.Type == "" {
+			tc.Failure.Type = "failure"
+		}
+	}
+}
+
+// strictTimestamp converts an RFC3339 timestamp to the format required by the
+// Ant JUnit XML schema, which does not allow a timezone.
+func strictTimestamp(value string) string {
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return value
+	}
+	return t.UTC().Format("2006-01-02T15:04:05")
+}
@@ -0,0 +1,79 @@
+package junitxml
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"gotest.tools/gotestsum/testjson"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/golden"
+)
+
+func TestWrite_Dialects(t *testing.T) {
+	for _, dialect := range []Dialect{DialectJenkins, DialectGitLab, DialectAzure, DialectAnt} {
+		t.Run(string(dialect), func(t *testing.T) {
+			exec := createExecution(t, testjson.ScanConfig{
+				Stdout: readTestData(t, "go-test-json.out"),
+				Stderr: readTestData(t, "go-test-json.err"),
+			})
+
+			t.Setenv("GOVERSION", "go7.7.7")
+			out := new(bytes.Buffer)
+			err := Write(out, exec, Config{
+				ProjectName:     "test",
+				Dialect:         dialect,
+				customTimestamp: new(time.Time).Format(time.RFC3339),
+				customElapsed:   "2.1",
+				customHostname:  "testhost",
+			})
+			assert.NilError(t, err)
+			golden.Assert(t, out.String(), "junitxml-report-dialect-"+string(dialect)+".golden")
+		})
+	}
+}
+
+func TestParseDialect(t *testing.T) {
+	d, err := ParseDialect("")
+	assert.NilError(t, err)
+	assert.Equal(t, d, DialectDefault)
+
+	d, err = ParseDialect("jenkins")
+	assert.NilError(t, err)
+	assert.Equal(t, d, DialectJenkins)
+
+	_, err = ParseDialect("bogus")
+	assert.Error(t, err, `invalid JUnit dialect "bogus", must be one of: default, jenkins, gitlab, azure, ant`)
+}
+
+func TestApplyDialect_AntRecountsTotals(t *testing.T) {
+	suites := JUnitTestSuites{
+		Tests:    4,
+		Failures: 1,
+		Errors:   2,
+		Suites: []JUnitTestSuite{{
+			Tests:    4,
+			Failures: 1,
+			Errors:   1,
+			Skipped:  1,
+			TestCases: []JUnitTestCase{
+				{Name: "TestFailed", Failure: &JUnitFailure{Message: "Failed"}},
+				{Name: "TestPanic", Error: &JUnitError{Message: "Panic"}},
+				{Name: "TestSkipped", SkipMessage: &JUnitSkipMessage{Message: "Skipped"}},
+				{Name: "TestPassed"},
+			},
+		}},
+	}
+	applyDialect(&suites, Config{Dialect: DialectAnt})
+
+	suite := suites.Suites[0]
+	assert.Equal(t, len(suite.TestCases), 3)
+	assert.Equal(t, suite.Tests, 3)
+	assert.Equal(t, suite.Failures, 1)
+	assert.Equal(t, suite.Errors, 1)
+	assert.Equal(t, suite.Skipped, 0)
+	// the error without a suite is still counted.
+	assert.Equal(t, suites.Tests, 3)
+	assert.Equal(t, suites.Failures, 1)
+	assert.Equal(t, suites.Errors, 2)
+}
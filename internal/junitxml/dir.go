@@ -0,0 +1,127 @@
+package junitxml
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gotest.tools/gotestsum/internal/log"
+	"gotest.tools/gotestsum/testjson"
+)
+
+// IndexFilename is the name of the index file written by WriteDir.
+const IndexFilename = "index.json"
+
+// Index lists the files written by WriteDir.
+type Index struct {
+	Files []IndexFile `json:"files"`
+}
+
+// IndexFile is the summary of one of the files written by WriteDir.
+type IndexFile struct {
+	File     string `json:"file"`
+	Package  string `json:"package"`
+	Tests    int    `json:"tests"`
+	Failures int    `json:"failures"`
+	Errors   int    `json:"errors"`
+	Skipped  int    `json:"skipped"`
+	Time     string `json:"time"`
+}
+
+// WriteDir creates one XML document for each package, and writes it to a file
+// in dir. The files are named from the import path of the package, using the
+// TEST-<name>.xml convention used by Ant and Maven. If cfg.DirIndex is true,
+// an index of the files is written to IndexFilename in dir.
+func WriteDir(dir string, exec *testjson.Execution, cfg Config) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create JUnit directory: %v", err)
+	}
+
+	suites := generate(exec, cfg)
+	var index Index
+	// Names are compared without case because some filesystems are not case
+	// sensitive.
+	seen := make(map[string]string)
+	for _, suite := range suites.Suites {
+		name := packageFilename(suite.importPath)
+		if other, ok := seen[strings.ToLower(name)]; ok {
+			return fmt.Errorf("JUnit file %v for package %v would overwrite the file for package %v",
+				name, suite.importPath, other)
+		}
+		seen[strings.ToLower(name)] = suite.importPath
+		single := JUnitTestSuites{
+			Name:     suites.Name,
+			Tests:    suite.Tests,
+			Failures: suite.Failures,
+			Errors:   suite.Errors,
+			Time:     suite.Time,
+			Suites:   []JUnitTestSuite{suite},
+			strict:   suites.strict,
+		}
+		if err := writeFile(filepath.Join(dir, name), single); err != nil {
+			return err
+		}
+		index.Files = append(index.Files, IndexFile{
+			File:     name,
+			Package:  suite.importPath,
+			Tests:    suite.Tests,
+			Failures: suite.Failures,
+			Errors:   suite.Errors,
+			Skipped:  suite.Skipped,
+			Time:     suite.Time,
+		})
+	}
+
+	if !cfg.DirIndex {
+		return nil
+	}
+	raw, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode JUnit index: %v", err)
+	}
+	raw = append(raw, '\n')
+	if err := os.WriteFile(filepath.Join(dir, IndexFilename), raw, 0o644); err != nil {
+		return fmt.Errorf("failed to write JUnit index: %v", err)
+	}
+	return nil
+}
+
+func writeFile(path string, suites JUnitTestSuites) error {
+	fh, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to open JUnit file: %v", err)
+	}
+	defer func() {
+		if err := fh.Close(); err != nil {
+			log.Errorf("Failed to close JUnit file %v: %v", path, err)
+		}
+	}()
+	if err := write(fh, suites); err != nil {
+		return fmt.Errorf("failed to write JUnit XML: %v", err)
+	}
+	return nil
+}
+
+// packageFilename returns the name of the file for the package with
+// importPath. Each / is replaced with an underscore. Any other byte which may
+// not be safe in a filename, including an underscore, is escaped as % followed
+// by its hex value, so that two import paths never have the same filename.
+func packageFilename(importPath string) string {
+	var name strings.Builder
+	for i := 0; i < len(importPath); i++ {
+		c := importPath[i]
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+			name.WriteByte(c)
+		case c == '.' || c == '-':
+			name.WriteByte(c)
+		case c == '/':
+			name.WriteByte('_')
+		default:
+			fmt.Fprintf(&name, "%%%02X", c)
+		}
+	}
+	return "TEST-" + name.String() + ".xml"
+}
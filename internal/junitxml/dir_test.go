@@ -0,0 +1,120 @@
+package junitxml
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gotest.tools/gotestsum/testjson"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/golden"
+)
+
+func TestWriteDir(t *testing.T) {
+	exec := createExecution(t, testjson.ScanConfig{
+		Stdout: readTestData(t, "go-test-json.out"),
+		Stderr: readTestData(t, "go-test-json.err"),
+	})
+
+	t.Setenv("GOVERSION", "go7.7.7")
+	dir := filepath.Join(t.TempDir(), "junit")
+	err := WriteDir(dir, exec, Config{
+		ProjectName:     "test",
+		DirIndex:        true,
+		customTimestamp: new(time.Time).Format(time.RFC3339),
+		customElapsed:   "2.1",
+	})
+	assert.NilError(t, err)
+
+	entries, err := os.ReadDir(dir)
+	assert.NilError(t, err)
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	expected := []string{
+		"TEST-gotest.tools_gotestsum_testjson_internal_badmain.xml",
+		"TEST-gotest.tools_gotestsum_testjson_internal_empty.xml",
+		"TEST-gotest.tools_gotestsum_testjson_internal_good.xml",
+		"TEST-gotest.tools_gotestsum_testjson_internal_parallelfails.xml",
+		"TEST-gotest.tools_gotestsum_testjson_internal_withfails.xml",
+		"index.json",
+	}
+	assert.DeepEqual(t, names, expected)
+
+	raw, err := os.ReadFile(filepath.Join(dir, IndexFilename))
+	assert.NilError(t, err)
+	golden.Assert(t, string(raw), "junitxml-dir-index.golden")
+
+	raw, err = os.ReadFile(filepath.Join(dir, expected[2]))
+	assert.NilError(t, err)
+	golden.Assert(t, string(raw), "junitxml-dir-package.golden")
+}
+
+func TestWriteDir_NoIndex(t *testing.T) {
+	exec := createExecution(t, testjson.ScanConfig{
+		Stdout: readTestData(t, "go-test-json.out"),
+	})
+
+	dir := t.TempDir()
+	err := WriteDir(dir, exec, Config{})
+	assert.NilError(t, err)
+
+	_, err = os.Stat(filepath.Join(dir, IndexFilename))
+	assert.Assert(t, os.IsNotExist(err))
+}
+
+func TestPackageFilename(t *testing.T) {
+	assert.Equal(t, packageFilename("example.com/org/pkg-name/v2"), "TEST-example.com_org_pkg-name_v2.xml")
+	assert.Equal(t, packageFilename("example.com/a b:c"), "TEST-example.com_a%20b%3Ac.xml")
+	assert.Equal(t, packageFilename("example.com/a_b"), "TEST-example.com_a%5Fb.xml")
+	assert.Equal(t, packageFilename("example.com/a/b"), "TEST-example.com_a_b.xml")
+}
+
+func TestWriteDir_ImportPathsWithSimilarNames(t *testing.T) {
+	exec := createExecution(t, testjson.ScanConfig{
+		Stdout: strings.NewReader(`{"Package": "example.com/a_b", "Test": "TestOne", "Action": "run"}
+{"Package": "example.com/a_b", "Test": "TestOne", "Action": "pass"}
+{"Package": "example.com/a_b", "Action": "pass"}
+{"Package": "example.com/a/b", "Test": "TestTwo", "Action": "run"}
+{"Package": "example.com/a/b", "Test": "TestTwo", "Action": "pass"}
+{"Package": "example.com/a/b", "Action": "pass"}
+`),
+	})
+
+	t.Setenv("GOVERSION", "go7.7.7")
+	dir := t.TempDir()
+	err := WriteDir(dir, exec, Config{DirIndex: true})
+	assert.NilError(t, err)
+
+	entries, err := os.ReadDir(dir)
+	assert.NilError(t, err)
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	expected := []string{
+		"TEST-example.com_a%5Fb.xml",
+		"TEST-example.com_a_b.xml",
+		"index.json",
+	}
+	assert.DeepEqual(t, names, expected)
+}
+
+func TestWriteDir_DuplicateFilename(t *testing.T) {
+	exec := createExecution(t, testjson.ScanConfig{
+		Stdout: strings.NewReader(`{"Package": "example.com/Pkg", "Test": "TestOne", "Action": "run"}
+{"Package": "example.com/Pkg", "Test": "TestOne", "Action": "pass"}
+{"Package": "example.com/Pkg", "Action": "pass"}
+{"Package": "example.com/pkg", "Test": "TestTwo", "Action": "run"}
+{"Package": "example.com/pkg", "Test": "TestTwo", "Action": "pass"}
+{"Package": "example.com/pkg", "Action": "pass"}
+`),
+	})
+
+	t.Setenv("GOVERSION", "go7.7.7")
+	err := WriteDir(t.TempDir(), exec, Config{})
+	assert.ErrorContains(t, err, "would overwrite the file for package")
+}
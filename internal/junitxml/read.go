@@ -0,0 +1,289 @@
+package junitxml
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"gotest.tools/gotestsum/testjson"
+)
+
+// Read a JUnit XML document and return the equivalent test2json events. Each
+// testsuite is used as a package, and each testcase as a test in that package.
+// The document may have either a testsuites or a testsuite root element.
+//
+// JUnit XML does not record the order or start time of each test, so tests
+// are given a start time that follows the previous test in the same suite,
+// starting from the timestamp of the suite. If the suite has no timestamp the
+// events have no Time.
+func Read(r io.Reader) ([]testjson.TestEvent, error) {
+	suites, err := decodeSuites(r)
+	if err != nil {
+		return nil, err
+	}
+	var events []testjson.TestEvent
+	for _, suite := range suites.Suites {
+		events = append(events, suiteEvents(suite)...)
+	}
+	return events, nil
+}
+
+// NewEventReader reads a JUnit XML document from r, and returns a reader of
+// the equivalent 'go test -json' output. The returned reader can be used as
+// the testjson.ScanConfig.Stdout.
+func NewEventReader(r io.Reader) (io.Reader, error) {
+	events, err := Read(r)
+	if err != nil {
+		return nil, err
+	}
+	buf := new(bytes.Buffer)
+	enc := json.NewEncoder(buf)
+	for _, event := range events {
+		if err := enc.Encode(newJSONEvent(event)); err != nil {
+			return nil, fmt.Errorf("failed to encode event: %v", err)
+		}
+	}
+	return buf, nil
+}
+
+// jsonEvent is the subset of testjson.TestEvent fields written by test2json.
+type jsonEvent struct {
+	Time    *time.Time      `json:",omitempty"`
+	Action  testjson.Action `json:"Action"`
+	Package string          `json:",omitempty"`
+	Test    string          `json:",omitempty"`
+	Elapsed *float64        `json:",omitempty"`
+	Output  string          `json:",omitempty"`
+}
+
+func newJSONEvent(event testjson.TestEvent) jsonEvent {
+	e := jsonEvent{
+		Action:  event.Action,
+		Package: event.Package,
+		Test:    event.Test,
+		Output:  event.Output,
+	}
+	if !event.Time.IsZero() {
+		e.Time = &event.Time
+	}
+	if event.Action.IsTerminal() {
+		e.Elapsed = &event.Elapsed
+	}
+	return e
+}
+
+func decodeSuites(r io.Reader) (JUnitTestSuites, error) {
+	var suites JUnitTestSuites
+	dec := xml.NewDecoder(r)
+	for {
+		token, err := dec.Token()
+		switch {
+		case errors.Is(err, io.EOF):
+			return suites, fmt.Errorf("failed to read JUnit XML: no testsuites or testsuite element")
+		case err != nil:
+			return suites, fmt.Errorf("failed to read JUnit XML: %v", err)
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		switch start.Name.Local {
+		case "testsuites":
+			err = dec.DecodeElement(&suites, &start)
+		case "testsuite":
+			var suite JUnitTestSuite
+			err = dec.DecodeElement(&suite, &start)
+			suites.Suites = append(suites.Suites, suite)
+		default:
+			return suites, fmt.Errorf("failed to read JUnit XML: unexpected root element %v", start.Name.Local)
+		}
+		if err != nil {
+			return suites, fmt.Errorf("failed to read JUnit XML: %v", err)
+		}
+		return suites, nil
+	}
+}
+
+func suiteEvents(suite JUnitTestSuite) []testjson.TestEvent {
+	pkg := suite.Name
+	now := parseTimestamp(suite.Timestamp)
+	// next returns the time of an event, and advances the clock by elapsed.
+	next := func(elapsed float64) time.Time {
+		if now.IsZero() {
+			return now
+		}
+		t := now
+		now = now.Add(time.Duration(elapsed * float64(time.Second)))
+		return t
+	}
+
+	failed := suite.Failures > 0 || suite.Errors > 0
+	var events []testjson.TestEvent
+	for _, tc := range suite.TestCases {
+		// The TestMain testcase written by gotestsum records the failure of
+		// the package, not a test.
+		if tc.Name == "TestMain" && tc.Error != nil && tc.Failure == nil {
+			events = append(events, outputEvents(next(0), pkg, "", tc.Error.Contents)...)
+			failed = true
+			continue
+		}
+
+		name := testName(pkg, tc)
+		addAttempt := func(action testjson.Action, output string, elapsed float64) {
+			start := next(0)
+			events = append(events, testjson.TestEvent{Time: start, Action: testjson.ActionRun, Package: pkg, Test: name})
+			events = append(events, outputEvents(start, pkg, name, output)...)
+			events = append(events, testjson.TestEvent{
+				Time:    next(elapsed),
+				Action:  action,
+				Package: pkg,
+				Test:    name,
+				Elapsed: elapsed,
+			})
+		}
+
+		// A <flakyFailure> is a failed attempt before the result of the
+		// testcase, and a <rerunFailure> is a failed attempt after the
+		// <failure> of the testcase.
+		for _, attempt := range tc.FlakyFailures {
+			addAttempt(testjson.ActionFail, attempt.StackTrace+attempt.SystemOut, parseSeconds(attempt.Time))
+		}
+		action, output := testCaseResult(tc)
+		addAttempt(action, output, parseSeconds(tc.Time))
+		for _, attempt := range tc.RerunFailures {
+			addAttempt(testjson.ActionFail, attempt.StackTrace+attempt.SystemOut, parseSeconds(attempt.Time))
+		}
+		if action == testjson.ActionFail {
+			failed = true
+		}
+	}
+
+	if suite.SystemOut != nil {
+		events = append(events, outputEvents(next(0), pkg, "", suite.SystemOut.Contents)...)
+	}
+	if suite.SystemErr != nil {
+		events = append(events, outputEvents(next(0), pkg, "", suite.SystemErr.Contents)...)
+	}
+
+	action := testjson.ActionPass
+	if failed {
+		action = testjson.ActionFail
+	}
+	return append(events, testjson.TestEvent{
+		Time:    next(0),
+		Action:  action,
+		Package: pkg,
+		Elapsed: parseSeconds(suite.Time),
+	})
+}
+
+// testName returns the name of the test for tc. Reports written by gotestsum
+// use the package as the classname, so only the name is used. Other tools use
+// the classname to identify the class or module of the test, so the classname
+// is added as a prefix of the name.
+func testName(suiteName string, tc JUnitTestCase) string {
+	switch {
+	case tc.Classname == "" || tc.Classname == suiteName:
+		return tc.Name
+	case path.Base(suiteName) == tc.Classname || strings.HasSuffix(suiteName, "/"+tc.Classname):
+		// the short and relative formats of the classname
+		return tc.Name
+	case strings.HasPrefix(tc.Classname, suiteName+"."):
+		// the jenkins dialect moves the root test into the classname
+		return strings.TrimPrefix(tc.Classname, suiteName+".") + "/" + tc.Name
+	default:
+		return tc.Classname + "." + tc.Name
+	}
+}
+
+// testCaseResult returns the action and the output of the result of tc, which
+// is not the last attempt when tc has rerunFailure elements.
+func testCaseResult(tc JUnitTestCase) (testjson.Action, string) {
+	switch {
+	case tc.Failure != nil:
+		return testjson.ActionFail, joinOutput(failureOutput(tc.Failure.Message, tc.Failure.Contents), tc.SystemOut)
+	case tc.Error != nil:
+		return testjson.ActionFail, joinOutput(failureOutput(tc.Error.Message, tc.Error.Contents), tc.SystemOut)
+	case tc.SkipMessage != nil:
+		// Reports written by gotestsum use the output of the test as the
+		// message.
+		return testjson.ActionSkip, joinOutput(tc.SkipMessage.Message, tc.SystemOut)
+	default:
+		return testjson.ActionPass, tc.SystemOut
+	}
+}
+
+// failureOutput returns the contents of a failure, or the message if there
+// are no contents.
+func failureOutput(message, contents string) string {
+	if strings.TrimSpace(contents) != "" {
+		return contents
+	}
+	return message
+}
+
+// joinOutput appends systemOut to output. Reports written by gotestsum with
+// --junitfile-include-output repeat the output of the test in system-out, so
+// systemOut is omitted when output already contains it.
+func joinOutput(output, systemOut string) string {
+	if strings.Contains(output, systemOut) {
+		return output
+	}
+	if output != "" && !strings.HasSuffix(output, "\n") {
+		output += "\n"
+	}
+	return output + systemOut
+}
+
+// outputEvents returns an output event for each line of output.
+func outputEvents(t time.Time, pkg, test, output string) []testjson.TestEvent {
+	if output == "" {
+		return nil
+	}
+	var events []testjson.TestEvent
+	for _, line := range strings.SplitAfter(output, "\n") {
+		if line == "" {
+			continue
+		}
+		if !strings.HasSuffix(line, "\n") {
+			line += "\n"
+		}
+		events = append(events, testjson.TestEvent{
+			Time:    t,
+			Action:  testjson.ActionOutput,
+			Package: pkg,
+			Test:    test,
+			Output:  line,
+		})
+	}
+	return events
+}
+
+// parseTimestamp parses the timestamp of a testsuite. The Ant JUnit XML schema
+// uses a timestamp with no timezone, which is treated as UTC.
+func parseTimestamp(value string) time.Time {
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// parseSeconds parses a time attribute, which is the elapsed time in seconds.
+// Some tools include a thousands separator, which is removed.
+func parseSeconds(value string) float64 {
+	seconds, err := strconv.ParseFloat(strings.ReplaceAll(value, ",", ""), 64)
+	if err != nil {
+		return 0
+	}
+	return seconds
+}
@@ -0,0 +1,125 @@
+package junitxml
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"gotest.tools/gotestsum/testjson"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/golden"
+)
+
+func TestNewEventReader(t *testing.T) {
+	fh, err := os.Open("testdata/junit-import.xml")
+	assert.NilError(t, err)
+	defer fh.Close() //nolint:errcheck
+
+	in, err := NewEventReader(fh)
+	assert.NilError(t, err)
+	raw, err := io.ReadAll(in)
+	assert.NilError(t, err)
+	golden.Assert(t, string(raw), "junit-import-events.golden")
+
+	exec, err := testjson.ScanTestOutput(testjson.ScanConfig{Stdout: bytes.NewReader(raw)})
+	assert.NilError(t, err)
+	assert.Equal(t, exec.Total(), 5)
+	assert.Equal(t, len(exec.Failed()), 2)
+	assert.Equal(t, len(exec.Skipped()), 1)
+	assert.Equal(t, exec.Elapsed(), 2750*time.Millisecond)
+}
+
+func TestRead_RoundTrip(t *testing.T) {
+	exec := createExecution(t, testjson.ScanConfig{
+		Stdout: readTestData(t, "go-test-json.out"),
+		Stderr: readTestData(t, "go-test-json.err"),
+	})
+
+	t.Setenv("GOVERSION", "go7.7.7")
+	out := new(bytes.Buffer)
+	err := Write(out, exec, Config{})
+	assert.NilError(t, err)
+
+	in, err := NewEventReader(out)
+	assert.NilError(t, err)
+	imported, err := testjson.ScanTestOutput(testjson.ScanConfig{Stdout: in})
+	assert.NilError(t, err)
+
+	assert.DeepEqual(t, imported.Packages(), exec.Packages())
+	assert.Equal(t, imported.Total(), exec.Total())
+	assert.Equal(t, len(imported.Failed()), len(exec.Failed()))
+	assert.Equal(t, len(imported.Skipped()), len(exec.Skipped()))
+	for _, name := range exec.Packages() {
+		assert.Equal(t, imported.Package(name).Result(), exec.Package(name).Result(), name)
+	}
+}
+
+func TestRead_OrderOfAttempts(t *testing.T) {
+	doc := `<testsuite name="pkg" tests="2" failures="1">
+	<testcase classname="pkg" name="TestFlaky" time="0.3">
+		<flakyFailure message="Failed" time="0.1"><stackTrace>flaky one</stackTrace></flakyFailure>
+		<flakyFailure message="Failed" time="0.2"><stackTrace>flaky two</stackTrace></flakyFailure>
+	</testcase>
+	<testcase classname="pkg" name="TestFails" time="0.1">
+		<failure message="Failed">first attempt</failure>
+		<rerunFailure message="Failed" time="0.2"><stackTrace>second attempt</stackTrace></rerunFailure>
+		<rerunFailure message="Failed" time="0.3"><stackTrace>third attempt</stackTrace></rerunFailure>
+	</testcase>
+</testsuite>`
+	events, err := Read(strings.NewReader(doc))
+	assert.NilError(t, err)
+
+	var actual []string
+	for _, event := range events {
+		switch {
+		case event.Action == testjson.ActionOutput:
+			actual = append(actual, event.Test+" "+strings.TrimSpace(event.Output))
+		case event.Action.IsTerminal() && event.Test != "":
+			actual = append(actual, event.Test+" "+string(event.Action))
+		}
+	}
+	expected := []string{
+		"TestFlaky flaky one",
+		"TestFlaky fail",
+		"TestFlaky flaky two",
+		"TestFlaky fail",
+		"TestFlaky pass",
+		"TestFails first attempt",
+		"TestFails fail",
+		"TestFails second attempt",
+		"TestFails fail",
+		"TestFails third attempt",
+		"TestFails fail",
+	}
+	assert.DeepEqual(t, actual, expected)
+}
+
+func TestRead_InvalidDocument(t *testing.T) {
+	_, err := Read(strings.NewReader(`<report></report>`))
+	assert.ErrorContains(t, err, "unexpected root element report")
+
+	_, err = Read(strings.NewReader(``))
+	assert.ErrorContains(t, err, "no testsuites or testsuite element")
+}
+
+func TestTestName(t *testing.T) {
+	suite := "example.com/project/pkg"
+	testCases := []struct {
+		classname string
+		expected  string
+	}{
+		{classname: "", expected: "TestOne"},
+		{classname: suite, expected: "TestOne"},
+		{classname: "pkg", expected: "TestOne"},
+		{classname: "project/pkg", expected: "TestOne"},
+		{classname: suite + ".TestRoot", expected: "TestRoot/TestOne"},
+		{classname: "tests.test_module", expected: "tests.test_module.TestOne"},
+	}
+	for _, tc := range testCases {
+		actual := testName(suite, JUnitTestCase{Classname: tc.classname, Name: "TestOne"})
+		assert.Equal(t, actual, tc.expected, tc.classname)
+	}
+}
@@ -3,29 +3,35 @@
 package junitxml
 
 import (
-	"bytes"
 	"encoding/xml"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
+	"regexp"
 	"slices"
+	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"gotest.tools/gotestsum/internal/log"
+	"gotest.tools/gotestsum/internal/testlocation"
+	"gotest.tools/gotestsum/internal/testresult"
 	"gotest.tools/gotestsum/testjson"
 )
 
 // JUnitTestSuites is a collection of JUnit test suites.
 type JUnitTestSuites struct {
-	XMLName  xml.Name `xml:"testsuites"`
-	Name     string   `xml:"name,attr,omitempty"`
-	Tests    int      `xml:"tests,attr"`
-	Failures int      `xml:"failures,attr"`
-	Errors   int      `xml:"errors,attr"`
-	Time     string   `xml:"time,attr"`
-	Suites   []JUnitTestSuite
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr,omitempty"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Errors   int              `xml:"errors,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []JUnitTestSuite `xml:"testsuite"`
+	// strict is set by the Ant dialect, which does not allow any attributes.
+	strict bool
 }
 
 // JUnitTestSuite is a single JUnit test suite which may contain many
@@ -34,12 +40,26 @@ type JUnitTestSuite struct {
 	XMLName    xml.Name        `xml:"testsuite"`
 	Tests      int             `xml:"tests,attr"`
 	Failures   int             `xml:"failures,attr"`
+	Errors     int             `xml:"errors,attr,omitempty"`
 	Skipped    int             `xml:"skipped,attr,omitempty"`
 	Time       string          `xml:"time,attr"`
 	Name       string          `xml:"name,attr"`
 	Properties []JUnitProperty `xml:"properties>property,omitempty"`
-	TestCases  []JUnitTestCase
-	Timestamp  string `xml:"timestamp,attr"`
+	TestCases  []JUnitTestCase `xml:"testcase"`
+	SystemOut  *JUnitOutput    `xml:"system-out,omitempty"`
+	SystemErr  *JUnitOutput    `xml:"system-err,omitempty"`
+	Timestamp  string          `xml:"timestamp,attr"`
+	Hostname   string          `xml:"hostname,attr,omitempty"`
+	ID         string          `xml:"id,attr,omitempty"`
+	Package    string          `xml:"package,attr,omitempty"`
+	// importPath is the import path of the package, before it is formatted by
+	// FormatTestSuiteName.
+	importPath string
+}
+
+// JUnitOutput is the output of a testsuite.
+type JUnitOutput struct {
+	Contents string `xml:",chardata"`
 }
 
 // JUnitTestCase is a single test case with its result.
@@ -48,9 +68,19 @@ type JUnitTestCase struct {
 	Classname   string            `xml:"classname,attr"`
 	Name        string            `xml:"name,attr"`
 	Time        string            `xml:"time,attr"`
+	File        string            `xml:"file,attr,omitempty"`
+	Line        int               `xml:"line,attr,omitempty"`
 	Properties  *JUnitProperties  `xml:"properties,omitempty"`
 	SkipMessage *JUnitSkipMessage `xml:"skipped,omitempty"`
 	Failure     *JUnitFailure     `xml:"failure,omitempty"`
+	Error       *JUnitError       `xml:"error,omitempty"`
+	// RerunFailures are the failed attempts which were rerun after the first
+	// Failure, when every attempt failed.
+	RerunFailures []JUnitRerunFailure `xml:"rerunFailure,omitempty"`
+	// FlakyFailures are the failed attempts of a test which passed when it
+	// was rerun.
+	FlakyFailures []JUnitRerunFailure `xml:"flakyFailure,omitempty"`
+	SystemOut     string              `xml:"system-out,omitempty"`
 }
 
 // JUnitSkipMessage contains the reason why a testcase was skipped.
@@ -72,11 +102,42 @@ type JUnitProperty struct {
 
 // JUnitFailure contains data related to a failed test.
 type JUnitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	// File and Line are the location of the first reference to a _test.go
+	// file in the failure output.
+	File     string `xml:"file,attr,omitempty"`
+	Line     int    `xml:"line,attr,omitempty"`
+	Contents string `xml:",chardata"`
+}
+
+// JUnitError contains data related to a test which did not complete, or a
+// package which could not run its tests. The Type is one of the ErrorType
+// constants.
+type JUnitError struct {
 	Message  string `xml:"message,attr"`
 	Type     string `xml:"type,attr"`
 	Contents string `xml:",chardata"`
 }
 
+// Values used for JUnitError.Type.
+const (
+	ErrorTypePanic    = "panic"
+	ErrorTypeTimeout  = "timeout"
+	ErrorTypeBuild    = "build"
+	ErrorTypeTestMain = "testmain"
+)
+
+// JUnitRerunFailure contains data related to a failed attempt of a test which
+// was rerun. The elements are the same ones used by the Maven Surefire plugin.
+type JUnitRerunFailure struct {
+	Message    string `xml:"message,attr"`
+	Type       string `xml:"type,attr"`
+	Time       string `xml:"time,attr"`
+	StackTrace string `xml:"stackTrace,omitempty"`
+	SystemOut  string `xml:"system-out,omitempty"`
+}
+
 // Config used to write a junit XML document.
 type Config struct {
 	ProjectName             string
@@ -84,9 +145,33 @@ type Config struct {
 	FormatTestCaseClassname FormatFunc
 	HideEmptyPackages       bool
 	HideSkippedTests        bool
+	// IncludeOutput adds the output of each test to the <system-out> of the
+	// testcase, and the package output and stderr to the <system-err> of the
+	// testsuite. The output of passed tests is only available when the
+	// Execution was created with testjson.ScanConfig.KeepPassedOutput.
+	IncludeOutput bool
+	// MaxOutputSize is the maximum number of bytes written to each
+	// <system-out> and <system-err>. Output larger than this size is truncated
+	// by removing bytes from the middle. Zero means no limit.
+	MaxOutputSize int
+	// CollapseReruns combines all the attempts of a test into a single
+	// testcase. When a test fails and then passes when it is rerun, the failed
+	// attempts are added as <flakyFailure>, and the testcase is not counted as
+	// a failure. When every attempt fails, the first attempt is the <failure>
+	// and the other attempts are added as <rerunFailure>.
+	CollapseReruns bool
+	// DirIndex is used by WriteDir to write an index of the files.
+	DirIndex bool
+	// Dialect adjusts the document for a known consumer. Defaults to
+	// DialectDefault.
+	Dialect Dialect
+	// TestLocations is used to set the file and line of each testcase. When
+	// nil the file and line are omitted.
+	TestLocations *testlocation.Index
 	// This is used for tests to have a consistent timestamp
 	customTimestamp string
 	customElapsed   string
+	customHostname  string
 }
 
 // FormatFunc converts a string from one format into another.
@@ -104,28 +189,30 @@ func generate(exec *testjson.Execution, cfg Config) JUnitTestSuites {
 	cfg = configWithDefaults(cfg)
 	version := goVersion()
 
-	total := exec.Total()
-	if cfg.HideSkippedTests && len(exec.Skipped()) > 0 {
-		total -= len(exec.Skipped())
-	}
-
 	suites := JUnitTestSuites{
-		Name:     cfg.ProjectName,
-		Tests:    total,
-		Failures: len(exec.Failed()),
-		Errors:   len(exec.Errors()),
-		Time:     formatDurationAsSeconds(exec.Elapsed()),
+		Name: cfg.ProjectName,
+		Time: formatDurationAsSeconds(exec.Elapsed()),
 	}
 
 	if cfg.customElapsed != "" {
 		suites.Time = cfg.customElapsed
 	}
 
+	var stderr map[string][]string
+	if cfg.IncludeOutput {
+		stderr = packageErrors(exec.Errors(), exec.Packages())
+	}
+
 	for _, pkgname := range exec.Packages() {
 		pkg := exec.Package(pkgname)
 		if cfg.HideEmptyPackages && pkg.IsEmpty() {
 			continue
 		}
+		// build-output events are not associated with a package, so they
+		// create an empty package with no name.
+		if pkgname == "" && pkg.IsEmpty() {
+			continue
+		}
 
 		if cfg.HideSkippedTests && len(pkg.Skipped) > 0 {
 			pkg.Total -= len(pkg.Skipped)
@@ -134,22 +221,68 @@ func generate(exec *testjson.Execution, cfg Config) JUnitTestSuites {
 
 		junitpkg := JUnitTestSuite{
 			Name:       cfg.FormatTestSuiteName(pkgname),
-			Tests:      pkg.Total,
+			importPath: pkgname,
 			Time:       formatDurationAsSeconds(pkg.Elapsed()),
 			Properties: packageProperties(version),
-			TestCases:  packageTestCases(pkg, cfg.FormatTestCaseClassname),
-			Failures:   len(pkg.Failed),
-			Skipped:    len(pkg.Skipped),
+			TestCases:  packageTestCases(exec, pkgname, cfg),
 			Timestamp:  cfg.customTimestamp,
 		}
 		if cfg.customTimestamp == "" {
 			junitpkg.Timestamp = pkg.Start.Format(time.RFC3339)
 		}
+		countTestCases(&junitpkg)
+		suites.Tests += junitpkg.Tests
+		suites.Failures += junitpkg.Failures
+		suites.Errors += junitpkg.Errors
+		if cfg.IncludeOutput {
+			output := pkg.Output(0) + strings.Join(stderr[pkgname], "")
+			junitpkg.SystemErr = &JUnitOutput{Contents: truncateOutput(output, cfg.MaxOutputSize)}
+		}
 		suites.Suites = append(suites.Suites, junitpkg)
 	}
+	suites.Errors += countErrorsWithoutSuite(exec, suites.Suites)
+	applyDialect(&suites, cfg)
 	return suites
 }
 
+// countTestCases sets the number of tests, failures, errors, and skipped tests
+// in the suite from its test cases.
+func countTestCases(suite *JUnitTestSuite) {
+	suite.Tests = len(suite.TestCases)
+	for _, tc := range suite.TestCases {
+		switch {
+		case tc.Failure != nil:
+			suite.Failures++
+		case tc.Error != nil:
+			suite.Errors++
+		case tc.SkipMessage != nil:
+			suite.Skipped++
+		}
+	}
+}
+
+// countErrorsWithoutSuite returns the number of errors from exec which are not
+// already counted as the build error of one of the suites.
+func countErrorsWithoutSuite(exec *testjson.Execution, suites []JUnitTestSuite) int {
+	counted := make(map[string]bool)
+	for _, suite := range suites {
+		build := exec.Package(suite.importPath).FailedBuild()
+		if build == "" {
+			continue
+		}
+		for _, line := range strings.SplitAfter(exec.BuildOutput(build), "\n") {
+			counted[line] = true
+		}
+	}
+	var count int
+	for _, line := range exec.Errors() {
+		if !counted[line] {
+			count++
+		}
+	}
+	return count
+}
+
 func configWithDefaults(cfg Config) Config {
 	noop := func(v string) string {
 		return v
@@ -164,6 +297,11 @@ func configWithDefaults(cfg Config) Config {
 }
 
 func formatDurationAsSeconds(d time.Duration) string {
+	// Tests which never finished, like a test that timed out, have a negative
+	// elapsed time.
+	if d < 0 {
+		d = 0
+	}
 	return fmt.Sprintf("%f", d.Seconds())
 }
 
@@ -193,51 +331,252 @@ func goVersion() string {
 	return strings.TrimPrefix(strings.TrimSpace(string(out)), "go version ")
 }
 
-func packageTestCases(pkg *testjson.Package, formatClassname FormatFunc) []JUnitTestCase {
+func packageTestCases(exec *testjson.Execution, pkgname string, cfg Config) []JUnitTestCase {
 	cases := []JUnitTestCase{}
+	pkg := exec.Package(pkgname)
 
-	if pkg.TestMainFailed() {
-		var buf bytes.Buffer
-		pkg.WriteOutputTo(&buf, 0) //nolint:errcheck
-		jtc := newJUnitTestCase(testjson.TestCase{Test: "TestMain"}, formatClassname)
-		jtc.Failure = &JUnitFailure{
-			Message:  "Failed",
-			Contents: buf.String(),
-		}
+	if pkg.TestMainFailed() && !timeoutInFailedTest(pkg) {
+		jtc := newJUnitTestCase(testjson.TestCase{Package: pkgname, Test: "TestMain"}, cfg)
+		jtc.Error = packageError(exec, pkg)
 		cases = append(cases, jtc)
 	}
 
+	if cfg.CollapseReruns {
+		return append(cases, collapsedTestCases(pkg, cfg)...)
+	}
+
 	for _, tc := range pkg.Failed {
-		jtc := newJUnitTestCase(tc, formatClassname)
-		jtc.Failure = &JUnitFailure{
-			Message:  "Failed",
-			Contents: strings.Join(pkg.OutputLines(tc), ""),
+		cases = append(cases, failedTestCase(pkg, tc, cfg))
+	}
+	for _, tc := range pkg.Skipped {
+		cases = append(cases, skippedTestCase(pkg, tc, cfg))
+	}
+	for _, tc := range pkg.Passed {
+		cases = append(cases, passedTestCase(pkg, tc, cfg))
+	}
+	return cases
+}
+
+// timeoutInFailedTest returns true when the panic from the test timeout was
+// attributed to a failed test. The error is reported on that test instead of
+// the package. The panic may also be attributed to a test which already passed,
+// in which case it is reported on the package.
+func timeoutInFailedTest(pkg *testjson.Package) bool {
+	name := pkg.TimeoutTest()
+	return name != "" && pkg.LastFailedByName(name).ID != 0
+}
+
+// packageError returns the error for a package that failed without any failed
+// tests.
+func packageError(exec *testjson.Execution, pkg *testjson.Package) *JUnitError {
+	output := pkg.Output(0)
+	switch {
+	case pkg.FailedBuild() != "" || strings.Contains(output, "[build failed]"):
+		return &JUnitError{
+			Message:  "Build failed",
+			Type:     ErrorTypeBuild,
+			Contents: exec.BuildOutput(pkg.FailedBuild()) + output,
 		}
-		cases = append(cases, jtc)
+	case pkg.TimedOut():
+		return &JUnitError{Message: "Test timed out", Type: ErrorTypeTimeout, Contents: output}
+	case pkg.Panicked():
+		return &JUnitError{Message: "Panic", Type: ErrorTypePanic, Contents: output}
+	default:
+		return &JUnitError{Message: "Failed", Type: ErrorTypeTestMain, Contents: output}
 	}
+}
 
-	for _, tc := range pkg.Skipped {
-		jtc := newJUnitTestCase(tc, formatClassname)
-		jtc.SkipMessage = &JUnitSkipMessage{
-			Message: strings.Join(pkg.OutputLines(tc), ""),
+func failedTestCase(pkg *testjson.Package, tc testjson.TestCase, cfg Config) JUnitTestCase {
+	jtc := newJUnitTestCase(tc, cfg)
+	output := strings.Join(pkg.OutputLines(tc), "")
+	switch {
+	case tc.Test.Name() == pkg.TimeoutTest():
+		jtc.Error = &JUnitError{
+			Message:  "Test timed out",
+			Type:     ErrorTypeTimeout,
+			Contents: output + pkg.Output(0),
 		}
-		cases = append(cases, jtc)
+	case testresult.HasPanic(pkg, output):
+		jtc.Error = &JUnitError{Message: "Panic", Type: ErrorTypePanic, Contents: output}
+	default:
+		jtc.Failure = &JUnitFailure{Message: "Failed", Contents: output}
+		jtc.Failure.File, jtc.Failure.Line = failureLocation(tc.Package, output, cfg)
 	}
+	jtc.SystemOut = systemOut(pkg, tc, cfg)
+	return jtc
+}
 
-	for _, tc := range pkg.Passed {
-		jtc := newJUnitTestCase(tc, formatClassname)
-		cases = append(cases, jtc)
+func skippedTestCase(pkg *testjson.Package, tc testjson.TestCase, cfg Config) JUnitTestCase {
+	jtc := newJUnitTestCase(tc, cfg)
+	jtc.SkipMessage = &JUnitSkipMessage{
+		Message: strings.Join(pkg.OutputLines(tc), ""),
+	}
+	jtc.SystemOut = systemOut(pkg, tc, cfg)
+	return jtc
+}
+
+func passedTestCase(pkg *testjson.Package, tc testjson.TestCase, cfg Config) JUnitTestCase {
+	jtc := newJUnitTestCase(tc, cfg)
+	jtc.SystemOut = systemOut(pkg, tc, cfg)
+	return jtc
+}
+
+// collapsedTestCases returns one testcase for each test name in the package,
+// in the same order used by packageTestCases: failed, skipped, then passed. The
+// result of a test is the result of its last attempt.
+func collapsedTestCases(pkg *testjson.Package, cfg Config) []JUnitTestCase {
+	var failed, skipped, passed []JUnitTestCase
+	for _, attempts := range testresult.ByName(pkg) {
+		var failures []testjson.TestCase
+		for _, a := range attempts {
+			if a.Action == testjson.ActionFail {
+				failures = append(failures, a.TestCase)
+			}
+		}
+
+		last := attempts[len(attempts)-1]
+		switch last.Action {
+		case testjson.ActionFail:
+			jtc := failedTestCase(pkg, failures[0], cfg)
+			for _, tc := range failures[1:] {
+				jtc.RerunFailures = append(jtc.RerunFailures, rerunFailure(pkg, tc, cfg))
+			}
+			failed = append(failed, jtc)
+		case testjson.ActionSkip:
+			jtc := skippedTestCase(pkg, last.TestCase, cfg)
+			jtc.FlakyFailures = rerunFailures(pkg, failures, cfg)
+			skipped = append(skipped, jtc)
+		default:
+			jtc := passedTestCase(pkg, last.TestCase, cfg)
+			jtc.FlakyFailures = rerunFailures(pkg, failures, cfg)
+			passed = append(passed, jtc)
+		}
+	}
+	return append(append(failed, skipped...), passed...)
+}
+
+func rerunFailures(pkg *testjson.Package, tcs []testjson.TestCase, cfg Config) []JUnitRerunFailure {
+	var result []JUnitRerunFailure
+	for _, tc := range tcs {
+		result = append(result, rerunFailure(pkg, tc, cfg))
+	}
+	return result
+}
+
+func rerunFailure(pkg *testjson.Package, tc testjson.TestCase, cfg Config) JUnitRerunFailure {
+	return JUnitRerunFailure{
+		Message:    "Failed",
+		Time:       formatDurationAsSeconds(tc.Elapsed),
+		StackTrace: strings.Join(pkg.OutputLines(tc), ""),
+		SystemOut:  systemOut(pkg, tc, cfg),
 	}
-	return cases
 }
 
-func newJUnitTestCase(tc testjson.TestCase, formatClassname FormatFunc) JUnitTestCase {
-	return JUnitTestCase{
-		Classname:  formatClassname(tc.Package),
+func newJUnitTestCase(tc testjson.TestCase, cfg Config) JUnitTestCase {
+	jtc := JUnitTestCase{
+		Classname:  cfg.FormatTestCaseClassname(tc.Package),
 		Name:       tc.Test.Name(),
 		Time:       formatDurationAsSeconds(tc.Elapsed),
 		Properties: encodeAttributes(tc.Attributes),
 	}
+	if loc, ok := cfg.TestLocations.Test(tc.Package, tc.Test); ok {
+		jtc.File, jtc.Line = loc.File, loc.Line
+	}
+	return jtc
+}
+
+var testFileLinePattern = regexp.MustCompile(`([\w.-]+_test\.go):(\d+):`)
+
+// failureLocation returns the file and line of the first reference to a
+// _test.go file in the failure output. The output only includes the base name
+// of the file, so no location is returned without cfg.TestLocations.
+func failureLocation(pkg string, output string, cfg Config) (string, int) {
+	if cfg.TestLocations == nil {
+		return "", 0
+	}
+	match := testFileLinePattern.FindStringSubmatch(output)
+	if match == nil {
+		return "", 0
+	}
+	line, err := strconv.Atoi(match[2])
+	if err != nil {
+		return "", 0
+	}
+	return cfg.TestLocations.File(pkg, match[1]), line
+}
+
+func systemOut(pkg *testjson.Package, tc testjson.TestCase, cfg Config) string {
+	if !cfg.IncludeOutput {
+		return ""
+	}
+	return truncateOutput(strings.Join(pkg.OutputLines(tc), ""), cfg.MaxOutputSize)
+}
+
+// packageErrors returns the lines of stderr from 'go test' grouped by the
+// package named in the line. stderr is not associated with any package, so a
+// line that does not name one of the packages is included for every package.
+func packageErrors(lines []string, pkgnames []string) map[string][]string {
+	result := make(map[string][]string)
+	for _, line := range lines {
+		line += "\n"
+		var found bool
+		for _, pkgname := range pkgnames {
+			if containsPackage(line, pkgname) {
+				result[pkgname] = append(result[pkgname], line)
+				found = true
+			}
+		}
+		if found {
+			continue
+		}
+		for _, pkgname := range pkgnames {
+			result[pkgname] = append(result[pkgname], line)
+		}
+	}
+	return result
+}
+
+// containsPackage returns true if line contains pkgname, and pkgname is not
+// a prefix of a longer package path.
+func containsPackage(line string, pkgname string) bool {
+	if pkgname == "" {
+		return false
+	}
+	for line != "" {
+		i := strings.Index(line, pkgname)
+		if i < 0 {
+			return false
+		}
+		line = line[i+len(pkgname):]
+		if line == "" || !isPathChar(line[0]) {
+			return true
+		}
+	}
+	return false
+}
+
+func isPathChar(c byte) bool {
+	return c == '/' || c == '.' || c == '-' || c == '_' ||
+		'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9'
+}
+
+// truncateOutput removes bytes from the middle of output so that it is no
+// larger than maxSize, and replaces them with a message. The start and end of
+// the output are kept because they are most likely to explain the result of
+// the test.
+func truncateOutput(output string, maxSize int) string {
+	if maxSize <= 0 || len(output) <= maxSize {
+		return output
+	}
+	head := maxSize / 2
+	for head > 0 && !utf8.RuneStart(output[head]) {
+		head--
+	}
+	tail := len(output) - (maxSize - maxSize/2)
+	for tail < len(output) && !utf8.RuneStart(output[tail]) {
+		tail++
+	}
+	return fmt.Sprintf("%s\n... %d bytes truncated ...\n%s", output[:head], tail-head, output[tail:])
 }
 
 // encodeAttributes encodes the given attributes into a JUnitProperties wrapper.
@@ -259,8 +598,27 @@ func encodeAttributes(attributes map[string]string) *JUnitProperties {
 	return &JUnitProperties{Properties: properties}
 }
 
+// strictTestSuite always writes the errors attribute, which is required by the
+// Ant JUnit XML schema. The attribute is omitted from other documents when
+// there are no errors.
+type strictTestSuite struct {
+	JUnitTestSuite
+	Errors int `xml:"errors,attr"`
+}
+
 func write(out io.Writer, suites JUnitTestSuites) error {
-	doc, err := xml.MarshalIndent(suites, "", "\t")
+	var v any = suites
+	if suites.strict {
+		strict := make([]strictTestSuite, 0, len(suites.Suites))
+		for _, suite := range suites.Suites {
+			strict = append(strict, strictTestSuite{JUnitTestSuite: suite, Errors: suite.Errors})
+		}
+		v = struct {
+			XMLName xml.Name `xml:"testsuites"`
+			Suites  []strictTestSuite
+		}{Suites: strict}
+	}
+	doc, err := xml.MarshalIndent(v, "", "\t")
 	if err != nil {
 		return err
 	}
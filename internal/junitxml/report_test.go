@@ -6,10 +6,12 @@ import (
 	"io"
 	"os"
 	"path"
+	"path/filepath"
 	"runtime"
 	"testing"
 	"time"
 
+	"gotest.tools/gotestsum/internal/testlocation"
 	"gotest.tools/gotestsum/testjson"
 	"gotest.tools/v3/assert"
 	"gotest.tools/v3/golden"
@@ -84,6 +86,91 @@ func TestWrite_WithAttributes(t *testing.T) {
 	golden.Assert(t, out.String(), "junitxml-report-tc-with-attributes.golden")
 }
 
+func TestWrite_IncludeOutput(t *testing.T) {
+	out := new(bytes.Buffer)
+	exec := createExecution(t, testjson.ScanConfig{
+		Stdout:           readTestData(t, "go-test-json.out"),
+		Stderr:           readTestData(t, "go-test-json.err"),
+		KeepPassedOutput: true,
+	})
+
+	t.Setenv("GOVERSION", "go7.7.7")
+	err := Write(out, exec, Config{
+		ProjectName:     "test",
+		IncludeOutput:   true,
+		MaxOutputSize:   400,
+		customTimestamp: new(time.Time).Format(time.RFC3339),
+		customElapsed:   "2.1",
+	})
+	assert.NilError(t, err)
+	golden.Assert(t, out.String(), "junitxml-report-with-output.golden")
+}
+
+func TestWrite_CollapseReruns(t *testing.T) {
+	exec := createExecution(t, testjson.ScanConfig{
+		Stdout: readTestData(t, "go-test-json.out"),
+		Stderr: readTestData(t, "go-test-json.err"),
+	})
+	exec = createExecution(t, testjson.ScanConfig{
+		RunID:     1,
+		Stdout:    readTestData(t, "go-test-json-rerun.out"),
+		Execution: exec,
+	})
+
+	t.Setenv("GOVERSION", "go7.7.7")
+	out := new(bytes.Buffer)
+	err := Write(out, exec, Config{
+		ProjectName:     "test",
+		CollapseReruns:  true,
+		customTimestamp: new(time.Time).Format(time.RFC3339),
+		customElapsed:   "2.1",
+	})
+	assert.NilError(t, err)
+	golden.Assert(t, out.String(), "junitxml-report-collapse-reruns.golden")
+}
+
+func TestWrite_Errors(t *testing.T) {
+	raw, err := os.ReadFile(path.Join("testdata", "go-test-json-errors.out"))
+	assert.NilError(t, err)
+	exec := createExecution(t, testjson.ScanConfig{Stdout: bytes.NewReader(raw)})
+
+	t.Setenv("GOVERSION", "go7.7.7")
+	out := new(bytes.Buffer)
+	err = Write(out, exec, Config{
+		ProjectName:     "test",
+		customTimestamp: new(time.Time).Format(time.RFC3339),
+		customElapsed:   "2.1",
+	})
+	assert.NilError(t, err)
+	golden.Assert(t, out.String(), "junitxml-report-errors.golden")
+}
+
+func TestWrite_WithTestLocations(t *testing.T) {
+	exec := createExecution(t, testjson.ScanConfig{
+		Stdout: readTestData(t, "go-test-json.out"),
+		Stderr: readTestData(t, "go-test-json.err"),
+	})
+
+	goldenFile, err := filepath.Abs("testdata/junitxml-report-with-locations.golden")
+	assert.NilError(t, err)
+	// file paths are relative to the working directory
+	t.Chdir("../..")
+	t.Setenv("GOFLAGS", "-tags=stubpkg")
+	locations, err := testlocation.Load(exec.Packages())
+	assert.NilError(t, err)
+
+	t.Setenv("GOVERSION", "go7.7.7")
+	out := new(bytes.Buffer)
+	err = Write(out, exec, Config{
+		ProjectName:     "test",
+		TestLocations:   locations,
+		customTimestamp: new(time.Time).Format(time.RFC3339),
+		customElapsed:   "2.1",
+	})
+	assert.NilError(t, err)
+	golden.Assert(t, out.String(), goldenFile)
+}
+
 func createExecution(t *testing.T, config testjson.ScanConfig) *testjson.Execution {
 	exec, err := testjson.ScanTestOutput(config)
 	assert.NilError(t, err)
@@ -153,3 +240,40 @@ func Test_encodeAttributes(t *testing.T) {
 		})
 	}
 }
+
+func TestTruncateOutput(t *testing.T) {
+	t.Run("no limit", func(t *testing.T) {
+		assert.Equal(t, truncateOutput("some output\n", 0), "some output\n")
+	})
+	t.Run("smaller than limit", func(t *testing.T) {
+		assert.Equal(t, truncateOutput("some output\n", 12), "some output\n")
+	})
+	t.Run("larger than limit", func(t *testing.T) {
+		actual := truncateOutput("first line\nmiddle line\nlast line\n", 20)
+		assert.Equal(t, actual, "first line\n... 13 bytes truncated ...\nlast line\n")
+	})
+	t.Run("does not split runes", func(t *testing.T) {
+		actual := truncateOutput("aaaéébbb", 8)
+		assert.Equal(t, actual, "aaa\n... 4 bytes truncated ...\nbbb")
+	})
+}
+
+func TestPackageErrors(t *testing.T) {
+	pkgs := []string{"example.com/foo", "example.com/foo/bar"}
+	lines := []string{
+		"example.com/foo/bar: some error",
+		"example.com/foo [setup failed]",
+		"example.com/foobar: not one of the packages",
+	}
+	expected := map[string][]string{
+		"example.com/foo": {
+			"example.com/foo [setup failed]\n",
+			"example.com/foobar: not one of the packages\n",
+		},
+		"example.com/foo/bar": {
+			"example.com/foo/bar: some error\n",
+			"example.com/foobar: not one of the packages\n",
+		},
+	}
+	assert.DeepEqual(t, packageErrors(lines, pkgs), expected)
+}
@@ -0,0 +1,407 @@
+/*Package nunitxml creates an NUnit 3 XML report from a testjson.Execution.
+ */
+package nunitxml
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gotest.tools/gotestsum/internal/testresult"
+	"gotest.tools/gotestsum/testjson"
+)
+
+// TestRun is the root of an NUnit 3 XML document.
+type TestRun struct {
+	XMLName       xml.Name    `xml:"test-run"`
+	ID            string      `xml:"id,attr"`
+	TestCaseCount int         `xml:"testcasecount,attr"`
+	Result        string      `xml:"result,attr"`
+	Total         int         `xml:"total,attr"`
+	Passed        int         `xml:"passed,attr"`
+	Failed        int         `xml:"failed,attr"`
+	Inconclusive  int         `xml:"inconclusive,attr"`
+	Skipped       int         `xml:"skipped,attr"`
+	Asserts       int         `xml:"asserts,attr"`
+	EngineVersion string      `xml:"engine-version,attr,omitempty"`
+	StartTime     string      `xml:"start-time,attr"`
+	EndTime       string      `xml:"end-time,attr"`
+	Duration      string      `xml:"duration,attr"`
+	Suites        []TestSuite `xml:"test-suite"`
+}
+
+// TestSuite is a package, or a test with subtests. The Type is
+// typeAssembly for a package, and typeParameterizedMethod for a test with
+// subtests.
+type TestSuite struct {
+	Type          string      `xml:"type,attr"`
+	ID            string      `xml:"id,attr"`
+	Name          string      `xml:"name,attr"`
+	FullName      string      `xml:"fullname,attr"`
+	ClassName     string      `xml:"classname,attr,omitempty"`
+	RunState      string      `xml:"runstate,attr"`
+	TestCaseCount int         `xml:"testcasecount,attr"`
+	Result        string      `xml:"result,attr"`
+	Label         string      `xml:"label,attr,omitempty"`
+	Site          string      `xml:"site,attr,omitempty"`
+	StartTime     string      `xml:"start-time,attr,omitempty"`
+	EndTime       string      `xml:"end-time,attr,omitempty"`
+	Duration      string      `xml:"duration,attr"`
+	Total         int         `xml:"total,attr"`
+	Passed        int         `xml:"passed,attr"`
+	Failed        int         `xml:"failed,attr"`
+	Warnings      int         `xml:"warnings,attr"`
+	Inconclusive  int         `xml:"inconclusive,attr"`
+	Skipped       int         `xml:"skipped,attr"`
+	Asserts       int         `xml:"asserts,attr"`
+	Properties    *Properties `xml:"properties,omitempty"`
+	Failure       *Failure    `xml:"failure,omitempty"`
+	Reason        *Reason     `xml:"reason,omitempty"`
+	Output        string      `xml:"output,omitempty"`
+	Suites        []TestSuite `xml:"test-suite"`
+	TestCases     []TestCase  `xml:"test-case"`
+}
+
+// TestCase is the result of a test with no subtests.
+type TestCase struct {
+	ID         string      `xml:"id,attr"`
+	Name       string      `xml:"name,attr"`
+	FullName   string      `xml:"fullname,attr"`
+	MethodName string      `xml:"methodname,attr"`
+	ClassName  string      `xml:"classname,attr"`
+	RunState   string      `xml:"runstate,attr"`
+	Result     string      `xml:"result,attr"`
+	Label      string      `xml:"label,attr,omitempty"`
+	StartTime  string      `xml:"start-time,attr,omitempty"`
+	EndTime    string      `xml:"end-time,attr,omitempty"`
+	Duration   string      `xml:"duration,attr"`
+	Asserts    int         `xml:"asserts,attr"`
+	Properties *Properties `xml:"properties,omitempty"`
+	Failure    *Failure    `xml:"failure,omitempty"`
+	Reason     *Reason     `xml:"reason,omitempty"`
+	Output     string      `xml:"output,omitempty"`
+}
+
+// Properties is a wrapper for the <properties> tag as encoding/xml would
+// otherwise always create an empty one.
+type Properties struct {
+	Properties []Property `xml:"property"`
+}
+
+// Property is a key/value pair attached to a test. The attributes emitted from
+// T.Attr are written as properties.
+type Property struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+// Failure contains the message and output of a failed test.
+type Failure struct {
+	Message    string `xml:"message"`
+	StackTrace string `xml:"stack-trace,omitempty"`
+}
+
+// Reason contains the message of a skipped test.
+type Reason struct {
+	Message string `xml:"message"`
+}
+
+const (
+	resultPassed  = "Passed"
+	resultFailed  = "Failed"
+	resultSkipped = "Skipped"
+
+	typeAssembly            = "Assembly"
+	typeParameterizedMethod = "ParameterizedMethod"
+
+	runStateRunnable = "Runnable"
+)
+
+// Config used to write an NUnit XML report.
+type Config struct {
+	// ToolVersion is the version of gotestsum.
+	ToolVersion string
+	// These are used for tests to have a consistent timestamp and elapsed time
+	customTimestamp time.Time
+	customElapsed   time.Duration
+}
+
+// Write creates an NUnit 3 XML document and writes it to out.
+func Write(out io.Writer, exec *testjson.Execution, cfg Config) error {
+	if err := write(out, generate(exec, cfg)); err != nil {
+		return fmt.Errorf("failed to write NUnit XML: %v", err)
+	}
+	return nil
+}
+
+func generate(exec *testjson.Execution, cfg Config) TestRun {
+	start, elapsed := exec.Started(), exec.Elapsed()
+	if !cfg.customTimestamp.IsZero() {
+		start, elapsed = cfg.customTimestamp, cfg.customElapsed
+	}
+	ids := &idGenerator{}
+	run := TestRun{
+		ID:            "0",
+		Result:        resultPassed,
+		EngineVersion: strings.TrimPrefix(cfg.ToolVersion, "v"),
+		StartTime:     formatTime(start),
+		EndTime:       formatTime(start.Add(elapsed)),
+		Duration:      formatSeconds(elapsed),
+	}
+	for _, name := range exec.Packages() {
+		suite := packageSuite(name, exec.Package(name), ids)
+		run.Suites = append(run.Suites, suite)
+		run.TestCaseCount += suite.TestCaseCount
+		run.Total += suite.Total
+		run.Passed += suite.Passed
+		run.Failed += suite.Failed
+		run.Skipped += suite.Skipped
+		if suite.Result == resultFailed {
+			run.Result = resultFailed
+		}
+	}
+	return run
+}
+
+func packageSuite(name string, pkg *testjson.Package, ids *idGenerator) TestSuite {
+	suite := TestSuite{
+		Type:     typeAssembly,
+		ID:       ids.next(),
+		Name:     name,
+		FullName: name,
+		RunState: runStateRunnable,
+		Result:   resultPassed,
+		Duration: formatSeconds(pkg.Elapsed()),
+	}
+	if pkg.TestMainFailed() {
+		lines := pkg.OutputLines(testjson.TestCase{})
+		suite.Result = resultFailed
+		suite.Label = "Error"
+		suite.Site = "SetUp"
+		suite.Failure = &Failure{
+			Message:    testresult.FirstLine(lines, "Failed"),
+			StackTrace: strings.Join(lines, ""),
+		}
+	}
+
+	root := newTree(testresult.Last(pkg))
+	for _, node := range root.children {
+		addNode(&suite, pkg, node, ids)
+	}
+	if suite.Result != resultFailed && hasFailedChild(&suite) {
+		childFailed(&suite)
+	}
+	return suite
+}
+
+// addNode adds a test to parent. A test with subtests is added as a nested
+// TestSuite, and all other tests are added as a TestCase.
+func addNode(parent *TestSuite, pkg *testjson.Package, n *node, ids *idGenerator) {
+	if len(n.children) == 0 {
+		tc := newTestCase(pkg, n.result, ids)
+		parent.TestCases = append(parent.TestCases, tc)
+		parent.TestCaseCount++
+		parent.Total++
+		switch tc.Result {
+		case resultPassed:
+			parent.Passed++
+		case resultFailed:
+			parent.Failed++
+		case resultSkipped:
+			parent.Skipped++
+		}
+		return
+	}
+
+	r := n.result
+	suite := TestSuite{
+		Type:       typeParameterizedMethod,
+		ID:         ids.next(),
+		Name:       r.Test.Name(),
+		FullName:   r.Package + "." + r.Test.Name(),
+		ClassName:  r.Package,
+		RunState:   runStateRunnable,
+		Result:     actionResults[r.Action],
+		StartTime:  startTime(r.TestCase),
+		EndTime:    endTime(r.TestCase),
+		Duration:   formatSeconds(r.Elapsed),
+		Properties: properties(r.Attributes),
+	}
+	addResultDetails(pkg, r, &suite.Failure, &suite.Reason, &suite.Output)
+	for _, child := range n.children {
+		addNode(&suite, pkg, child, ids)
+	}
+	switch {
+	case hasFailedChild(&suite):
+		childFailed(&suite)
+	case suite.Result == resultFailed:
+		suite.Site = "Test"
+	}
+
+	parent.Suites = append(parent.Suites, suite)
+	parent.TestCaseCount += suite.TestCaseCount
+	parent.Total += suite.Total
+	parent.Passed += suite.Passed
+	parent.Failed += suite.Failed
+	parent.Skipped += suite.Skipped
+}
+
+// hasFailedChild returns true if a test case in suite failed, or if a nested
+// suite failed. A test with subtests fails when its own code fails, even if
+// all of its subtests passed, so the result of a nested suite is not always
+// included in the Failed count.
+func hasFailedChild(suite *TestSuite) bool {
+	if suite.Failed > 0 {
+		return true
+	}
+	for _, s := range suite.Suites {
+		if s.Result == resultFailed {
+			return true
+		}
+	}
+	return false
+}
+
+// childFailed marks suite as failed because one of its children failed. NUnit
+// uses the same message when a child test fails.
+func childFailed(suite *TestSuite) {
+	suite.Result = resultFailed
+	suite.Site = "Child"
+	if suite.Failure == nil || suite.Failure.Message == "Failed" {
+		suite.Failure = &Failure{Message: "One or more child tests had errors"}
+	}
+}
+
+// actionResults maps the action that ended a test to the NUnit result.
+var actionResults = map[testjson.Action]string{
+	testjson.ActionPass: resultPassed,
+	testjson.ActionFail: resultFailed,
+	testjson.ActionSkip: resultSkipped,
+}
+
+func newTestCase(pkg *testjson.Package, r testresult.Attempt, ids *idGenerator) TestCase {
+	tc := TestCase{
+		ID:         ids.next(),
+		Name:       r.Test.Name(),
+		FullName:   r.Package + "." + r.Test.Name(),
+		MethodName: r.Test.Name(),
+		ClassName:  r.Package,
+		RunState:   runStateRunnable,
+		Result:     actionResults[r.Action],
+		StartTime:  startTime(r.TestCase),
+		EndTime:    endTime(r.TestCase),
+		Duration:   formatSeconds(r.Elapsed),
+		Properties: properties(r.Attributes),
+	}
+	addResultDetails(pkg, r, &tc.Failure, &tc.Reason, &tc.Output)
+	return tc
+}
+
+func addResultDetails(pkg *testjson.Package, r testresult.Attempt, failure **Failure, reason **Reason, output *string) {
+	lines := pkg.OutputLines(r.TestCase)
+	switch r.Action {
+	case testjson.ActionFail:
+		*failure = &Failure{Message: testresult.FirstLine(lines, "Failed")}
+		*output = strings.Join(lines, "")
+	case testjson.ActionSkip:
+		*reason = &Reason{Message: testresult.FirstLine(lines, "Skipped")}
+		*output = strings.Join(lines, "")
+	}
+}
+
+func properties(attrs map[string]string) *Properties {
+	if len(attrs) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(attrs))
+	for key := range attrs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	p := &Properties{}
+	for _, key := range keys {
+		p.Properties = append(p.Properties, Property{Name: key, Value: attrs[key]})
+	}
+	return p
+}
+
+type idGenerator struct {
+	last int
+}
+
+// next returns the next ID. NUnit uses IDs with the format
+// <assembly>-<test>, and gotestsum always uses 0 for the assembly.
+func (g *idGenerator) next() string {
+	g.last++
+	return "0-" + strconv.Itoa(1000+g.last)
+}
+
+// node is a test in the tree of tests and subtests of a package.
+type node struct {
+	result   testresult.Attempt
+	children []*node
+}
+
+// newTree returns the root of the tree of results. Results are added in the
+// order they started, so the parent of a subtest is always added before the
+// subtest. A subtest with no parent in results is added to the root.
+func newTree(results []testresult.Attempt) *node {
+	root := &node{}
+	byName := make(map[string]*node)
+	for _, r := range results {
+		n := &node{result: r}
+		byName[r.Test.Name()] = n
+
+		parent := root
+		name := r.Test.Name()
+		for i := strings.LastIndex(name, "/"); i > 0; i = strings.LastIndex(name, "/") {
+			name = name[:i]
+			if p, ok := byName[name]; ok {
+				parent = p
+				break
+			}
+		}
+		parent.children = append(parent.children, n)
+	}
+	return root
+}
+
+func startTime(tc testjson.TestCase) string {
+	if tc.Time.IsZero() {
+		return ""
+	}
+	return formatTime(tc.Time)
+}
+
+func endTime(tc testjson.TestCase) string {
+	if tc.Time.IsZero() {
+		return ""
+	}
+	return formatTime(tc.Time.Add(tc.Elapsed))
+}
+
+// formatTime uses the format of timestamps written by NUnit 3.
+func formatTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02 15:04:05Z")
+}
+
+func formatSeconds(d time.Duration) string {
+	return fmt.Sprintf("%.6f", d.Seconds())
+}
+
+func write(out io.Writer, run TestRun) error {
+	doc, err := xml.MarshalIndent(run, "", "\t")
+	if err != nil {
+		return err
+	}
+	_, err = out.Write([]byte(xml.Header))
+	if err != nil {
+		return err
+	}
+	_, err = out.Write(doc)
+	return err
+}
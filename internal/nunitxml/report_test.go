@@ -0,0 +1,148 @@
+package nunitxml
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"gotest.tools/gotestsum/internal/reporttest"
+	"gotest.tools/gotestsum/testjson"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/golden"
+)
+
+func TestWrite(t *testing.T) {
+	out := new(bytes.Buffer)
+	exec := reporttest.CreateExecution(t, testjson.ScanConfig{
+		Stdout: reporttest.ReadTestData(t, "go-test-json.out"),
+		Stderr: reporttest.ReadTestData(t, "go-test-json.err"),
+	})
+
+	err := Write(out, exec, testConfig())
+	assert.NilError(t, err)
+	golden.Assert(t, out.String(), "nunitxml-report.golden")
+}
+
+func TestGenerate_SubtestsAreNestedSuites(t *testing.T) {
+	exec := reporttest.CreateExecution(t, testjson.ScanConfig{
+		Stdout: strings.NewReader(`{"Package": "pkg", "Test": "TestA", "Action": "run"}
+{"Package": "pkg", "Test": "TestA/one", "Action": "run"}
+{"Package": "pkg", "Test": "TestA/one", "Action": "pass"}
+{"Package": "pkg", "Test": "TestA/two", "Action": "run"}
+{"Package": "pkg", "Test": "TestA/two/nested", "Action": "run"}
+{"Package": "pkg", "Test": "TestA/two/nested", "Action": "fail"}
+{"Package": "pkg", "Test": "TestA/two", "Action": "fail"}
+{"Package": "pkg", "Test": "TestA", "Action": "fail"}
+{"Package": "pkg", "Test": "TestB", "Action": "run"}
+{"Package": "pkg", "Test": "TestB", "Action": "pass"}
+{"Package": "pkg", "Action": "fail"}
+`),
+	})
+
+	run := generate(exec, testConfig())
+	assert.Equal(t, run.Result, resultFailed)
+	assert.Equal(t, run.Total, 3)
+	assert.Equal(t, run.Failed, 1)
+
+	pkg := run.Suites[0]
+	assert.Equal(t, pkg.Type, typeAssembly)
+	assert.Equal(t, pkg.Site, "Child")
+	assert.Equal(t, len(pkg.TestCases), 1)
+	assert.Equal(t, pkg.TestCases[0].Name, "TestB")
+
+	testA := pkg.Suites[0]
+	assert.Equal(t, testA.Type, typeParameterizedMethod)
+	assert.Equal(t, testA.Name, "TestA")
+	assert.Equal(t, testA.Total, 2)
+	assert.Equal(t, testA.Passed, 1)
+	assert.Equal(t, testA.Failed, 1)
+	assert.Equal(t, testA.Site, "Child")
+	assert.Equal(t, testA.Failure.Message, "One or more child tests had errors")
+	assert.Equal(t, testA.TestCases[0].Name, "TestA/one")
+
+	two := testA.Suites[0]
+	assert.Equal(t, two.Name, "TestA/two")
+	assert.Equal(t, two.Result, resultFailed)
+	assert.Equal(t, len(two.TestCases), 1)
+	assert.Equal(t, two.TestCases[0].Name, "TestA/two/nested")
+	assert.Equal(t, two.TestCases[0].Result, resultFailed)
+}
+
+func TestGenerate_ParentFailsWithPassingSubtests(t *testing.T) {
+	exec := reporttest.CreateExecution(t, testjson.ScanConfig{
+		Stdout: strings.NewReader(`{"Package": "pkg", "Test": "TestP", "Action": "run"}
+{"Package": "pkg", "Test": "TestP/a", "Action": "run"}
+{"Package": "pkg", "Test": "TestP/a", "Action": "pass"}
+{"Package": "pkg", "Test": "TestP", "Action": "output", "Output": "    p_test.go:9: cleanup failed\n"}
+{"Package": "pkg", "Test": "TestP", "Action": "fail"}
+{"Package": "pkg", "Action": "fail"}
+`),
+	})
+
+	run := generate(exec, testConfig())
+	assert.Equal(t, run.Result, resultFailed)
+	assert.Equal(t, run.Failed, 0)
+
+	pkg := run.Suites[0]
+	assert.Equal(t, pkg.Result, resultFailed)
+	assert.Equal(t, pkg.Site, "Child")
+
+	testP := pkg.Suites[0]
+	assert.Equal(t, testP.Name, "TestP")
+	assert.Equal(t, testP.Result, resultFailed)
+	assert.Equal(t, testP.Site, "Test")
+	assert.Equal(t, testP.Passed, 1)
+}
+
+func TestGenerate_ReportsTheLastAttempt(t *testing.T) {
+	exec := reporttest.NewExecution(t, `{"Package": "pkg", "Test": "TestFlaky", "Action": "run"}
+{"Package": "pkg", "Test": "TestFlaky", "Action": "output", "Output": "    flaky_test.go:8: timed out\n"}
+{"Package": "pkg", "Test": "TestFlaky", "Action": "fail"}
+{"Package": "pkg", "Test": "TestOK", "Action": "run"}
+{"Package": "pkg", "Test": "TestOK", "Action": "pass"}
+{"Package": "pkg", "Action": "fail"}
+`)
+	exec = reporttest.CreateExecution(t, testjson.ScanConfig{
+		RunID: 1,
+		Stdout: strings.NewReader(`{"Package": "pkg", "Test": "TestFlaky", "Action": "run"}
+{"Package": "pkg", "Test": "TestFlaky", "Action": "pass"}
+{"Package": "pkg", "Action": "pass"}
+`),
+		Execution: exec,
+	})
+
+	run := generate(exec, testConfig())
+	assert.Equal(t, run.Result, resultPassed)
+	assert.Equal(t, run.Total, 2)
+	assert.Equal(t, run.Passed, 2)
+
+	tcs := run.Suites[0].TestCases
+	assert.Equal(t, tcs[0].Name, "TestFlaky")
+	assert.Equal(t, tcs[0].Result, resultPassed)
+	assert.Assert(t, tcs[0].Failure == nil)
+	assert.Equal(t, tcs[1].Name, "TestOK")
+}
+
+func TestGenerate_AttributesAreProperties(t *testing.T) {
+	exec := reporttest.NewExecution(t, `{"Package": "pkg", "Test": "TestA", "Action": "run"}
+{"Package": "pkg", "Test": "TestA", "Action": "attr", "Key": "owner", "Value": "team-a"}
+{"Package": "pkg", "Test": "TestA", "Action": "attr", "Key": "issue", "Value": "1234"}
+{"Package": "pkg", "Test": "TestA", "Action": "pass"}
+{"Package": "pkg", "Action": "pass"}
+`)
+
+	tc := generate(exec, testConfig()).Suites[0].TestCases[0]
+	assert.DeepEqual(t, tc.Properties, &Properties{Properties: []Property{
+		{Name: "issue", Value: "1234"},
+		{Name: "owner", Value: "team-a"},
+	}})
+}
+
+func testConfig() Config {
+	return Config{
+		ToolVersion:     "v7.7.7",
+		customTimestamp: time.Date(2022, 6, 19, 13, 45, 0, 0, time.UTC),
+		customElapsed:   2 * time.Second,
+	}
+}
@@ -0,0 +1,199 @@
+/*Package quarantine reads a list of known flaky tests, which are allowed to fail.
+ */
+package quarantine
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+	"gotest.tools/gotestsum/internal/log"
+	"gotest.tools/gotestsum/testjson"
+)
+
+// File is the format of a quarantine file. The file may be YAML or JSON.
+//
+//	tests:
+//	  - package: example.com/project/pkg
+//	    test: TestFlaky
+//	    reason: fails when the network is slow
+//	    owner: someone@example.com
+//	    expires: 2025-12-31
+type File struct {
+	Tests []Entry `yaml:"tests" json:"tests"`
+}
+
+// Entry is a test, or a group of tests, in the quarantine list.
+type Entry struct {
+	// Package is the import path of the package. An empty Package matches the
+	// tests in every package.
+	Package string `yaml:"package" json:"package"`
+	// Test is a regular expression that must match the full name of the test.
+	// The subtests of a matching test are also quarantined.
+	Test   string `yaml:"test" json:"test"`
+	Reason string `yaml:"reason" json:"reason"`
+	Owner  string `yaml:"owner" json:"owner"`
+	// Expires is the date, in the format YYYY-MM-DD, after which the test is
+	// no longer quarantined. An empty Expires never expires.
+	Expires string `yaml:"expires" json:"expires"`
+
+	pattern *regexp.Regexp
+}
+
+const dateFormat = "2006-01-02"
+
+// List of quarantined tests.
+type List struct {
+	entries []Entry
+}
+
+// Load the quarantine file at path. Entries which expired before now are
+// logged as a warning, and are not included in the List.
+func Load(path string, now time.Time) (*List, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read quarantine file: %w", err)
+	}
+
+	var file File
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = json.Unmarshal(raw, &file)
+	} else {
+		err = yaml.Unmarshal(raw, &file)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse quarantine file %v: %w", path, err)
+	}
+	return newList(file, now)
+}
+
+func newList(file File, now time.Time) (*List, error) {
+	today := now.Format(dateFormat)
+	list := &List{}
+	for i, entry := range file.Tests {
+		if entry.Test == "" {
+			return nil, fmt.Errorf("quarantine entry %d: test is required", i+1)
+		}
+		pattern, err := regexp.Compile("^(?:" + entry.Test + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("quarantine entry %d: invalid test pattern: %w", i+1, err)
+		}
+		entry.pattern = pattern
+
+		if entry.Expires != "" {
+			expires, err := time.Parse(dateFormat, entry.Expires)
+			if err != nil {
+				return nil, fmt.Errorf("quarantine entry %d: invalid expires date: %w", i+1, err)
+			}
+			if expires.Format(dateFormat) < today {
+				log.Warnf("Quarantine of %v expired on %v%v, failures will not be ignored",
+					entry.name(), entry.Expires, formatOwner(entry.Owner))
+				continue
+			}
+		}
+		list.entries = append(list.entries, entry)
+	}
+	return list, nil
+}
+
+func (e Entry) name() string {
+	if e.Package == "" {
+		return e.Test
+	}
+	return e.Package + " " + e.Test
+}
+
+func formatOwner(owner string) string {
+	if owner == "" {
+		return ""
+	}
+	return " (owner " + owner + ")"
+}
+
+// Match returns the entry that matches the test, and true, if the test, or one
+// of its parents, is quarantined.
+func (l *List) Match(pkg string, test testjson.TestName) (Entry, bool) {
+	if l == nil {
+		return Entry{}, false
+	}
+	for _, entry := range l.entries {
+		if entry.Package != "" && entry.Package != pkg {
+			continue
+		}
+		for name := test.Name(); name != ""; name = testjson.TestName(name).Parent() {
+			if entry.pattern.MatchString(name) {
+				return entry, true
+			}
+		}
+	}
+	return Entry{}, false
+}
+
+// Exclude returns the test cases from tcs which are not quarantined.
+func (l *List) Exclude(tcs []testjson.TestCase) []testjson.TestCase {
+	if l == nil {
+		return tcs
+	}
+	var result []testjson.TestCase
+	for _, tc := range tcs {
+		if _, ok := l.Match(tc.Package, tc.Test); !ok {
+			result = append(result, tc)
+		}
+	}
+	return result
+}
+
+// Attr is an attribute added to a quarantined test.
+type Attr struct {
+	Key   string
+	Value string
+}
+
+// Attrs returns the attributes of the test, or nil if the test is not
+// quarantined. The testjson.AttrQuarantined attribute is set to the reason,
+// and the owner and expiry date are added when they are set.
+func (l *List) Attrs(pkg string, test testjson.TestName) []Attr {
+	entry, ok := l.Match(pkg, test)
+	if !ok {
+		return nil
+	}
+	reason := entry.Reason
+	if reason == "" {
+		reason = "true"
+	}
+	attrs := []Attr{{Key: testjson.AttrQuarantined, Value: reason}}
+	if entry.Owner != "" {
+		attrs = append(attrs, Attr{Key: testjson.AttrQuarantined + ".owner", Value: entry.Owner})
+	}
+	if entry.Expires != "" {
+		attrs = append(attrs, Attr{Key: testjson.AttrQuarantined + ".expires", Value: entry.Expires})
+	}
+	return attrs
+}
+
+// Apply adds the Attrs of every quarantined test in exec to the test, so that
+// the tests are marked as quarantined in the summary and the reports.
+func (l *List) Apply(exec *testjson.Execution) {
+	if l == nil {
+		return
+	}
+	for _, name := range exec.Packages() {
+		pkg := exec.Package(name)
+		seen := make(map[testjson.TestName]bool)
+		for _, tc := range pkg.TestCases() {
+			if seen[tc.Test] {
+				continue
+			}
+			seen[tc.Test] = true
+
+			for _, attr := range l.Attrs(name, tc.Test) {
+				pkg.AddAttribute(tc.Test, attr.Key, attr.Value)
+			}
+		}
+	}
+}
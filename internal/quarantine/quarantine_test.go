@@ -0,0 +1,132 @@
+package quarantine
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"gotest.tools/gotestsum/testjson"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/fs"
+)
+
+var now = time.Date(2025, 3, 4, 5, 6, 7, 0, time.UTC)
+
+func TestLoad(t *testing.T) {
+	for _, filename := range []string{"testdata/quarantine.yaml", "testdata/quarantine.json"} {
+		t.Run(filename, func(t *testing.T) {
+			list, err := Load(filename, now)
+			assert.NilError(t, err)
+
+			entry, ok := list.Match("example.com/project/fs", "TestFlaky")
+			assert.Assert(t, ok)
+			assert.Equal(t, entry.Reason, "fails when the disk is slow")
+			assert.Equal(t, entry.Owner, "storage-team")
+			assert.Equal(t, entry.Expires, "2030-06-30")
+
+			_, ok = list.Match("example.com/project/pkg", "TestExpired")
+			assert.Assert(t, !ok, "expired entries should not match")
+		})
+	}
+}
+
+func TestList_Match(t *testing.T) {
+	list, err := Load("testdata/quarantine.yaml", now)
+	assert.NilError(t, err)
+
+	type testCase struct {
+		pkg      string
+		test     testjson.TestName
+		expected bool
+	}
+	for _, tc := range []testCase{
+		{pkg: "example.com/project/fs", test: "TestFlaky", expected: true},
+		{pkg: "example.com/project/fs", test: "TestFlaky/subtest", expected: true},
+		{pkg: "example.com/project/fs", test: "TestFlakyOther", expected: false},
+		{pkg: "example.com/project/other", test: "TestFlaky", expected: false},
+		{pkg: "example.com/project/other", test: "TestNetworkTimeout", expected: true},
+		{pkg: "example.com/project/other", test: "TestNetwork/with_proxy", expected: true},
+		{pkg: "example.com/project/other", test: "TestOther/TestNetwork", expected: false},
+	} {
+		_, ok := list.Match(tc.pkg, tc.test)
+		assert.Equal(t, ok, tc.expected, "%v %v", tc.pkg, tc.test)
+	}
+}
+
+func TestList_Match_NilList(t *testing.T) {
+	var list *List
+	_, ok := list.Match("example.com/project/fs", "TestFlaky")
+	assert.Assert(t, !ok)
+}
+
+func TestList_Exclude(t *testing.T) {
+	list, err := Load("testdata/quarantine.yaml", now)
+	assert.NilError(t, err)
+
+	tcs := []testjson.TestCase{
+		{Package: "example.com/project/fs", Test: "TestFlaky/subtest"},
+		{Package: "example.com/project/fs", Test: "TestOne"},
+		{Package: "example.com/project/other", Test: "TestNetworkTimeout"},
+	}
+	actual := list.Exclude(tcs)
+	assert.Equal(t, len(actual), 1)
+	assert.Equal(t, actual[0].Test, testjson.TestName("TestOne"))
+
+	var nilList *List
+	assert.Equal(t, len(nilList.Exclude(tcs)), 3)
+}
+
+func TestLoad_Errors(t *testing.T) {
+	type testCase struct {
+		content  string
+		expected string
+	}
+	for name, tc := range map[string]testCase{
+		"missing test": {
+			content:  "tests:\n  - package: example.com/pkg\n",
+			expected: "quarantine entry 1: test is required",
+		},
+		"invalid pattern": {
+			content:  "tests:\n  - test: Test(\n",
+			expected: "quarantine entry 1: invalid test pattern",
+		},
+		"invalid expires": {
+			content:  "tests:\n  - test: TestOne\n    expires: next week\n",
+			expected: "quarantine entry 1: invalid expires date",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			file := fs.NewFile(t, "quarantine.yaml", fs.WithContent(tc.content))
+			_, err := Load(file.Path(), now)
+			assert.ErrorContains(t, err, tc.expected)
+		})
+	}
+}
+
+func TestList_Apply(t *testing.T) {
+	list, err := Load("testdata/quarantine.yaml", now)
+	assert.NilError(t, err)
+
+	exec, err := testjson.ScanTestOutput(testjson.ScanConfig{
+		Stdout: strings.NewReader(`{"Package": "example.com/project/fs", "Test": "TestFlaky", "Action": "run"}
+{"Package": "example.com/project/fs", "Test": "TestFlaky", "Action": "fail"}
+{"Package": "example.com/project/fs", "Test": "TestFlaky", "Action": "run"}
+{"Package": "example.com/project/fs", "Test": "TestFlaky", "Action": "pass"}
+{"Package": "example.com/project/fs", "Test": "TestOther", "Action": "run"}
+{"Package": "example.com/project/fs", "Test": "TestOther", "Action": "fail"}
+{"Package": "example.com/project/fs", "Action": "fail"}
+`),
+	})
+	assert.NilError(t, err)
+	list.Apply(exec)
+
+	pkg := exec.Package("example.com/project/fs")
+	expected := map[string]string{
+		testjson.AttrQuarantined:              "fails when the disk is slow",
+		testjson.AttrQuarantined + ".owner":   "storage-team",
+		testjson.AttrQuarantined + ".expires": "2030-06-30",
+	}
+	assert.DeepEqual(t, pkg.Failed[0].Attributes, expected)
+	assert.DeepEqual(t, pkg.Passed[0].Attributes, expected)
+	assert.Assert(t, pkg.Failed[1].Attributes == nil)
+}
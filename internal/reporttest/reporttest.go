@@ -0,0 +1,47 @@
+/*Package reporttest provides the test input shared by the tests of the report
+writers.
+*/
+package reporttest
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"gotest.tools/gotestsum/testjson"
+	"gotest.tools/v3/assert"
+)
+
+// CreateExecution scans the test2json output in config, and fails the test if
+// the output can not be scanned.
+func CreateExecution(t *testing.T, config testjson.ScanConfig) *testjson.Execution {
+	t.Helper()
+	exec, err := testjson.ScanTestOutput(config)
+	assert.NilError(t, err)
+	return exec
+}
+
+// NewExecution scans the test2json events in source.
+func NewExecution(t *testing.T, source string) *testjson.Execution {
+	t.Helper()
+	return CreateExecution(t, testjson.ScanConfig{Stdout: strings.NewReader(source)})
+}
+
+// ReadTestData returns the contents of inputFile from testjson/testdata/input.
+func ReadTestData(t *testing.T, inputFile string) io.Reader {
+	t.Helper()
+	raw, err := os.ReadFile(filepath.Join(inputDir(), inputFile))
+	assert.NilError(t, err)
+	return bytes.NewReader(raw)
+}
+
+// inputDir returns the path to testjson/testdata/input, relative to the source
+// of this package, so that it does not depend on the package of the test.
+func inputDir() string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Join(filepath.Dir(file), "../../testjson/testdata/input")
+}
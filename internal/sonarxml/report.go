@@ -0,0 +1,132 @@
+/*Package sonarxml creates a SonarQube generic test execution report from a testjson.Execution.
+ */
+package sonarxml
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"gotest.tools/gotestsum/internal/log"
+	"gotest.tools/gotestsum/internal/testlocation"
+	"gotest.tools/gotestsum/internal/testresult"
+	"gotest.tools/gotestsum/testjson"
+)
+
+// TestExecutions is the root of a Generic Test Execution document.
+type TestExecutions struct {
+	XMLName xml.Name `xml:"testExecutions"`
+	Version int      `xml:"version,attr"`
+	Files   []File   `xml:"file"`
+}
+
+// File contains the tests declared in a single test file. Path is relative to
+// the current working directory, which should be the root of the project
+// analysed by SonarQube.
+type File struct {
+	Path      string     `xml:"path,attr"`
+	TestCases []TestCase `xml:"testCase"`
+}
+
+// TestCase is the result of a single test. Duration is in milliseconds.
+type TestCase struct {
+	Name     string   `xml:"name,attr"`
+	Duration int64    `xml:"duration,attr"`
+	Skipped  *Message `xml:"skipped,omitempty"`
+	Failure  *Message `xml:"failure,omitempty"`
+	Error    *Message `xml:"error,omitempty"`
+}
+
+// Message is the short message and output of a skipped, failed, or error
+// test.
+type Message struct {
+	Message  string `xml:"message,attr"`
+	Contents string `xml:",chardata"`
+}
+
+// Config used to write a SonarQube report.
+type Config struct {
+	// TestLocations is used to find the file of each test. Tests which are not
+	// in TestLocations are omitted from the report, because every test must
+	// belong to a file.
+	TestLocations *testlocation.Index
+}
+
+// Write creates a Generic Test Execution XML document and writes it to out.
+func Write(out io.Writer, exec *testjson.Execution, cfg Config) error {
+	if err := write(out, generate(exec, cfg)); err != nil {
+		return fmt.Errorf("failed to write SonarQube XML: %v", err)
+	}
+	return nil
+}
+
+func generate(exec *testjson.Execution, cfg Config) TestExecutions {
+	byPath := make(map[string]*File)
+	var missing int
+	for _, name := range exec.Packages() {
+		pkg := exec.Package(name)
+		for _, r := range testresult.Last(pkg) {
+			loc, ok := cfg.TestLocations.Test(r.Package, r.Test)
+			if !ok {
+				log.Debugf("failed to find the file of test %v %v", r.Package, r.Test.Name())
+				missing++
+				continue
+			}
+			file, ok := byPath[loc.File]
+			if !ok {
+				file = &File{Path: loc.File}
+				byPath[loc.File] = file
+			}
+			file.TestCases = append(file.TestCases, newTestCase(pkg, r))
+		}
+	}
+	if missing > 0 {
+		log.Warnf("Omitted %d tests from the SonarQube report because their file was not found", missing)
+	}
+
+	doc := TestExecutions{Version: 1}
+	for _, file := range byPath {
+		doc.Files = append(doc.Files, *file)
+	}
+	sort.Slice(doc.Files, func(i, j int) bool {
+		return doc.Files[i].Path < doc.Files[j].Path
+	})
+	return doc
+}
+
+func newTestCase(pkg *testjson.Package, r testresult.Attempt) TestCase {
+	tc := TestCase{
+		Name:     r.Test.Name(),
+		Duration: r.Elapsed.Milliseconds(),
+	}
+	lines := pkg.OutputLines(r.TestCase)
+	output := strings.Join(lines, "")
+	switch r.Action {
+	case testjson.ActionSkip:
+		tc.Skipped = &Message{Message: testresult.FirstLine(lines, "Skipped"), Contents: output}
+	case testjson.ActionFail:
+		msg := &Message{Message: testresult.FirstLine(lines, "Failed"), Contents: output}
+		// Sonar uses error for tests which did not complete.
+		if testresult.Incomplete(pkg, r.TestCase) {
+			tc.Error = msg
+		} else {
+			tc.Failure = msg
+		}
+	}
+	return tc
+}
+
+func write(out io.Writer, doc TestExecutions) error {
+	raw, err := xml.MarshalIndent(doc, "", "\t")
+	if err != nil {
+		return err
+	}
+	_, err = out.Write([]byte(xml.Header))
+	if err != nil {
+		return err
+	}
+	_, err = out.Write(raw)
+	return err
+}
@@ -0,0 +1,46 @@
+package sonarxml
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"gotest.tools/gotestsum/internal/testlocation"
+	"gotest.tools/gotestsum/internal/reporttest"
+	"gotest.tools/gotestsum/testjson"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/golden"
+)
+
+func TestWrite(t *testing.T) {
+	exec := reporttest.CreateExecution(t, testjson.ScanConfig{
+		Stdout: reporttest.ReadTestData(t, "go-test-json.out"),
+		Stderr: reporttest.ReadTestData(t, "go-test-json.err"),
+	})
+
+	goldenFile, err := filepath.Abs("testdata/sonarxml-report.golden")
+	assert.NilError(t, err)
+	// file paths are relative to the working directory
+	t.Chdir("../..")
+	t.Setenv("GOFLAGS", "-tags=stubpkg")
+	locations, err := testlocation.Load(exec.Packages())
+	assert.NilError(t, err)
+
+	out := new(bytes.Buffer)
+	err = Write(out, exec, Config{TestLocations: locations})
+	assert.NilError(t, err)
+	golden.Assert(t, out.String(), goldenFile)
+}
+
+func TestWrite_WithoutTestLocations(t *testing.T) {
+	exec := reporttest.CreateExecution(t, testjson.ScanConfig{
+		Stdout: reporttest.ReadTestData(t, "go-test-json.out"),
+	})
+
+	out := new(bytes.Buffer)
+	err := Write(out, exec, Config{})
+	assert.NilError(t, err)
+	expected := `<?xml version="1.0" encoding="UTF-8"?>
+<testExecutions version="1"></testExecutions>`
+	assert.Equal(t, out.String(), expected)
+}
@@ -0,0 +1,144 @@
+/*Package testlocation finds the source file and line of test functions.
+ */
+package testlocation
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+	"gotest.tools/gotestsum/internal/log"
+	"gotest.tools/gotestsum/testjson"
+)
+
+// Location of a function in a source file.
+type Location struct {
+	// File is the path to the file, relative to the current working directory
+	// when possible.
+	File string
+	Line int
+}
+
+// Index of test functions by package.
+type Index struct {
+	tests map[string]map[string]Location
+	dirs  map[string]string
+}
+
+// Load parses the test files of the packages and returns an Index of the
+// functions declared in those files. Packages which fail to load are logged and
+// omitted from the Index.
+func Load(pkgNames []string) (*Index, error) {
+	idx := &Index{
+		tests: make(map[string]map[string]Location),
+		dirs:  make(map[string]string),
+	}
+	// packages.Load loads the package in the current directory when there
+	// are no patterns.
+	if len(pkgNames) == 0 {
+		return idx, nil
+	}
+
+	fset := token.NewFileSet()
+	cfg := packages.Config{
+		Mode:       packages.NeedName | packages.NeedFiles | packages.NeedSyntax,
+		Tests:      true,
+		Fset:       fset,
+		BuildFlags: BuildFlags(),
+	}
+	pkgs, err := packages.Load(&cfg, pkgNames...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load packages: %v", err)
+	}
+
+	cwd, _ := os.Getwd()
+	for _, pkg := range pkgs {
+		if len(pkg.Errors) > 0 {
+			log.Debugf("failed to load package %v: %v", pkg.PkgPath, pkg.Errors[0])
+		}
+		pkgPath := NormalizePkgName(pkg.PkgPath)
+		for _, file := range pkg.Syntax {
+			path := fset.File(file.Pos()).Name()
+			if !strings.HasSuffix(path, "_test.go") {
+				continue
+			}
+			idx.dirs[pkgPath] = relativePath(cwd, filepath.Dir(path))
+			idx.addFuncs(pkgPath, file, fset, relativePath(cwd, path))
+		}
+	}
+	return idx, nil
+}
+
+func (i *Index) addFuncs(pkgPath string, file *ast.File, fset *token.FileSet, path string) {
+	if i.tests[pkgPath] == nil {
+		i.tests[pkgPath] = make(map[string]Location)
+	}
+	for _, decl := range file.Decls {
+		fd, ok := decl.(*ast.FuncDecl)
+		if !ok || fd.Recv != nil {
+			continue
+		}
+		i.tests[pkgPath][fd.Name.Name] = Location{
+			File: path,
+			Line: fset.Position(fd.Pos()).Line,
+		}
+	}
+}
+
+// Test returns the location of the function for the test. Subtests return the
+// location of the function for the root test.
+func (i *Index) Test(pkg string, test testjson.TestName) (Location, bool) {
+	if i == nil {
+		return Location{}, false
+	}
+	name := test.Name()
+	if test.IsSubTest() {
+		name, _ = test.Split()
+	}
+	loc, ok := i.tests[pkg][name]
+	return loc, ok
+}
+
+// File returns the path to the file with base name in the directory of pkg.
+// If the directory of pkg is not known, base is returned.
+func (i *Index) File(pkg string, base string) string {
+	if i == nil {
+		return base
+	}
+	dir, ok := i.dirs[pkg]
+	if !ok {
+		return base
+	}
+	return filepath.ToSlash(filepath.Join(dir, base))
+}
+
+// NormalizePkgName removes the _test suffix from a package name. External test
+// packages (those named package_test) may contain tests, but the test2json
+// output always uses the non-external package name. The _test suffix must be
+// removed so that the tests in an external test package can be found.
+func NormalizePkgName(name string) string {
+	return strings.TrimSuffix(name, "_test")
+}
+
+func relativePath(cwd string, path string) string {
+	if cwd != "" {
+		if rel, err := filepath.Rel(cwd, path); err == nil && !strings.HasPrefix(rel, "..") {
+			path = rel
+		}
+	}
+	return filepath.ToSlash(path)
+}
+
+// BuildFlags returns the flags from GOFLAGS, which are used as the build flags
+// when loading packages.
+func BuildFlags() []string {
+	flags := os.Getenv("GOFLAGS")
+	if len(flags) == 0 {
+		return nil
+	}
+	return strings.Split(flags, " ")
+}
@@ -0,0 +1,35 @@
+package testlocation
+
+import (
+	"testing"
+
+	"gotest.tools/gotestsum/testjson"
+	"gotest.tools/v3/assert"
+)
+
+func TestLoad(t *testing.T) {
+	pkg := "gotest.tools/gotestsum/internal/testlocation"
+	idx, err := Load([]string{pkg})
+	assert.NilError(t, err)
+
+	loc, ok := idx.Test(pkg, "TestLoad")
+	assert.Assert(t, ok)
+	assert.Equal(t, loc, Location{File: "location_test.go", Line: 10})
+
+	loc, ok = idx.Test(pkg, "TestLoad/subtest")
+	assert.Assert(t, ok)
+	assert.Equal(t, loc, Location{File: "location_test.go", Line: 10})
+
+	_, ok = idx.Test(pkg, "TestMissing")
+	assert.Assert(t, !ok)
+
+	assert.Equal(t, idx.File(pkg, "other_test.go"), "other_test.go")
+	assert.Equal(t, idx.File("example.com/unknown", "other_test.go"), "other_test.go")
+}
+
+func TestIndex_Nil(t *testing.T) {
+	var idx *Index
+	_, ok := idx.Test("example.com/pkg", testjson.TestName("TestOne"))
+	assert.Assert(t, !ok)
+	assert.Equal(t, idx.File("example.com/pkg", "one_test.go"), "one_test.go")
+}
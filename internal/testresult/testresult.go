@@ -0,0 +1,105 @@
+/*Package testresult lists the results of the tests in a package for test reports.
+ */
+package testresult
+
+import (
+	"sort"
+	"strings"
+
+	"gotest.tools/gotestsum/testjson"
+)
+
+// Attempt is a single run of a test, and the action that ended it.
+type Attempt struct {
+	testjson.TestCase
+	Action testjson.Action
+}
+
+// Attempts returns every attempt of every test in the package, sorted by RunID
+// and the order the tests started.
+func Attempts(pkg *testjson.Package) []Attempt {
+	var all []Attempt
+	for _, tc := range pkg.Passed {
+		all = append(all, Attempt{TestCase: tc, Action: testjson.ActionPass})
+	}
+	for _, tc := range pkg.Failed {
+		all = append(all, Attempt{TestCase: tc, Action: testjson.ActionFail})
+	}
+	for _, tc := range pkg.Skipped {
+		all = append(all, Attempt{TestCase: tc, Action: testjson.ActionSkip})
+	}
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].RunID != all[j].RunID {
+			return all[i].RunID < all[j].RunID
+		}
+		return all[i].ID < all[j].ID
+	})
+	return all
+}
+
+// ByName returns every attempt of every test in the package, grouped by the
+// name of the test. The groups are sorted by the order the first attempt
+// started, and the attempts in each group are sorted by RunID.
+func ByName(pkg *testjson.Package) [][]Attempt {
+	var result [][]Attempt
+	index := make(map[testjson.TestName]int)
+	for _, a := range Attempts(pkg) {
+		i, ok := index[a.Test]
+		if !ok {
+			i = len(result)
+			index[a.Test] = i
+			result = append(result, nil)
+		}
+		result[i] = append(result[i], a)
+	}
+	return result
+}
+
+// Last returns the last attempt of each test in the package, sorted by the
+// order the first attempt started. A test which was run more than once, by
+// --rerun-fails or go test -count, is reported with its last result.
+func Last(pkg *testjson.Package) []Attempt {
+	groups := ByName(pkg)
+	results := make([]Attempt, 0, len(groups))
+	for _, attempts := range groups {
+		results = append(results, attempts[len(attempts)-1])
+	}
+	return results
+}
+
+// FirstLine returns the first line of test output that is not one of the
+// lines printed by go test to frame the output of a test, or def if there is
+// no such line.
+func FirstLine(lines []string, def string) string {
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "=== "), strings.HasPrefix(line, "--- "):
+		default:
+			return line
+		}
+	}
+	return def
+}
+
+// HasPanic returns true if the package panicked, and the output has a line that
+// starts a panic, followed by the goroutine trace printed by the runtime. A test
+// which only prints a line starting with "panic: " is not reported as a panic.
+func HasPanic(pkg *testjson.Package, output string) bool {
+	if !pkg.Panicked() {
+		return false
+	}
+	i := strings.Index("\n"+output, "\npanic: ")
+	return i >= 0 && strings.Contains(output[i:], "\ngoroutine ")
+}
+
+// Incomplete returns true if the failed test did not complete, because of a
+// panic or the test timeout. Some report formats use an error, instead of a
+// failure, for these tests.
+func Incomplete(pkg *testjson.Package, tc testjson.TestCase) bool {
+	if tc.Test.Name() == pkg.TimeoutTest() {
+		return true
+	}
+	return HasPanic(pkg, strings.Join(pkg.OutputLines(tc), ""))
+}
@@ -0,0 +1,90 @@
+package testresult
+
+import (
+	"strings"
+	"testing"
+
+	"gotest.tools/gotestsum/testjson"
+	"gotest.tools/v3/assert"
+)
+
+func TestLast(t *testing.T) {
+	exec, err := testjson.ScanTestOutput(testjson.ScanConfig{
+		Stdout: strings.NewReader(`{"Package": "pkg", "Test": "TestOne", "Action": "run"}
+{"Package": "pkg", "Test": "TestOne", "Action": "fail"}
+{"Package": "pkg", "Test": "TestTwo", "Action": "run"}
+{"Package": "pkg", "Test": "TestTwo", "Action": "skip"}
+{"Package": "pkg", "Action": "fail"}
+`),
+	})
+	assert.NilError(t, err)
+	exec, err = testjson.ScanTestOutput(testjson.ScanConfig{
+		RunID:     1,
+		Execution: exec,
+		Stdout: strings.NewReader(`{"Package": "pkg", "Test": "TestOne", "Action": "run"}
+{"Package": "pkg", "Test": "TestOne", "Action": "pass"}
+{"Package": "pkg", "Action": "pass"}
+`),
+	})
+	assert.NilError(t, err)
+
+	pkg := exec.Package("pkg")
+	assert.Equal(t, len(Attempts(pkg)), 3)
+
+	var actual []string
+	for _, a := range Last(pkg) {
+		actual = append(actual, a.Test.Name()+" "+string(a.Action))
+	}
+	assert.DeepEqual(t, actual, []string{"TestOne pass", "TestTwo skip"})
+
+	var byName []string
+	for _, attempts := range ByName(pkg) {
+		names := attempts[0].Test.Name()
+		for _, a := range attempts {
+			names += " " + string(a.Action)
+		}
+		byName = append(byName, names)
+	}
+	assert.DeepEqual(t, byName, []string{"TestOne fail pass", "TestTwo skip"})
+}
+
+func TestFirstLine(t *testing.T) {
+	lines := []string{
+		"=== RUN   TestOne\n",
+		"\n",
+		"    one_test.go:10: the message\n",
+		"--- FAIL: TestOne (0.00s)\n",
+	}
+	assert.Equal(t, FirstLine(lines, "Failed"), "one_test.go:10: the message")
+	assert.Equal(t, FirstLine(lines[:2], "Failed"), "Failed")
+}
+
+func TestHasPanic(t *testing.T) {
+	trace := "panic: boom\n\ngoroutine 7 [running]:\n"
+	panicked := newPackage(t, `{"Package": "pkg", "Test": "TestOne", "Action": "run"}
+{"Package": "pkg", "Test": "TestOne", "Action": "output", "Output": "panic: boom\n"}
+{"Package": "pkg", "Test": "TestOne", "Action": "output", "Output": "\n"}
+{"Package": "pkg", "Test": "TestOne", "Action": "output", "Output": "goroutine 7 [running]:\n"}
+{"Package": "pkg", "Test": "TestOne", "Action": "fail"}
+{"Package": "pkg", "Action": "fail"}
+`)
+	assert.Assert(t, HasPanic(panicked, trace))
+	assert.Assert(t, HasPanic(panicked, "=== RUN   TestOne\n"+trace))
+	assert.Assert(t, !HasPanic(panicked, "the message was not "+trace))
+
+	// TestTwo only printed a line that looks like a panic, the test binary
+	// did not panic.
+	printed := newPackage(t, `{"Package": "pkg", "Test": "TestTwo", "Action": "run"}
+{"Package": "pkg", "Test": "TestTwo", "Action": "output", "Output": "panic: boom\n"}
+{"Package": "pkg", "Test": "TestTwo", "Action": "fail"}
+{"Package": "pkg", "Action": "fail"}
+`)
+	assert.Assert(t, !HasPanic(printed, "panic: boom\n"))
+}
+
+func newPackage(t *testing.T, source string) *testjson.Package {
+	t.Helper()
+	exec, err := testjson.ScanTestOutput(testjson.ScanConfig{Stdout: strings.NewReader(source)})
+	assert.NilError(t, err)
+	return exec.Package("pkg")
+}
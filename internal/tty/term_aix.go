@@ -1,7 +1,7 @@
 //go:build aix
 // +build aix
 
-package filewatcher
+package tty
 
 import "golang.org/x/sys/unix"
 
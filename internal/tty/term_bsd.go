@@ -1,7 +1,7 @@
 //go:build darwin || dragonfly || freebsd || netbsd || openbsd
 // +build darwin dragonfly freebsd netbsd openbsd
 
-package filewatcher
+package tty
 
 import "golang.org/x/sys/unix"
 
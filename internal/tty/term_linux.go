@@ -1,4 +1,4 @@
-package filewatcher
+package tty
 
 import "golang.org/x/sys/unix"
 
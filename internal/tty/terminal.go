@@ -0,0 +1,42 @@
+//go:build !windows
+// +build !windows
+
+/*
+Package tty changes the input mode of a terminal so that key presses can
+be read one at a time.
+*/
+package tty
+
+import (
+	"golang.org/x/sys/unix"
+	"gotest.tools/gotestsum/internal/log"
+)
+
+// SetInputMode disables echo and canonical mode on the terminal fd, so that
+// each key press can be read as soon as it is typed. minBytes and timeout
+// set the minimum number of bytes and the timeout, in tenths of a second, of
+// a read (see VMIN and VTIME in termios(3)).
+//
+// The returned function resets the terminal to its previous mode.
+func SetInputMode(fd int, minBytes, timeout uint8) (func(), error) {
+	term, err := unix.IoctlGetTermios(fd, tcGet)
+	if err != nil {
+		return nil, err
+	}
+
+	state := *term
+	reset := func() {
+		if err := unix.IoctlSetTermios(fd, tcSet, &state); err != nil {
+			log.Debugf("failed to reset fd %d: %v", fd, err)
+		}
+	}
+
+	term.Lflag &^= unix.ECHO | unix.ICANON
+	term.Cc[unix.VMIN] = minBytes
+	term.Cc[unix.VTIME] = timeout
+	if err := unix.IoctlSetTermios(fd, tcSet, term); err != nil {
+		reset()
+		return nil, err
+	}
+	return reset, nil
+}
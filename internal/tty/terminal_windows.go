@@ -0,0 +1,8 @@
+package tty
+
+import "errors"
+
+// SetInputMode is not supported on windows.
+func SetInputMode(int, uint8, uint8) (func(), error) {
+	return nil, errors.New("terminal input mode is not supported on windows")
+}
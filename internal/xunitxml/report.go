@@ -0,0 +1,249 @@
+/*Package xunitxml creates an xUnit.net v2 XML report from a testjson.Execution.
+ */
+package xunitxml
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"gotest.tools/gotestsum/internal/testresult"
+	"gotest.tools/gotestsum/testjson"
+)
+
+// Assemblies is the root of an xUnit.net v2 XML document.
+type Assemblies struct {
+	XMLName    xml.Name   `xml:"assemblies"`
+	Timestamp  string     `xml:"timestamp,attr"`
+	Assemblies []Assembly `xml:"assembly"`
+}
+
+// Assembly contains the results of the tests in a package.
+type Assembly struct {
+	Name          string       `xml:"name,attr"`
+	TestFramework string       `xml:"test-framework,attr"`
+	RunDate       string       `xml:"run-date,attr"`
+	RunTime       string       `xml:"run-time,attr"`
+	Time          string       `xml:"time,attr"`
+	Total         int          `xml:"total,attr"`
+	Passed        int          `xml:"passed,attr"`
+	Failed        int          `xml:"failed,attr"`
+	Skipped       int          `xml:"skipped,attr"`
+	Errors        int          `xml:"errors,attr"`
+	ErrorList     *Errors      `xml:"errors,omitempty"`
+	Collections   []Collection `xml:"collection"`
+}
+
+// Errors is the list of errors which were not caused by a test.
+type Errors struct {
+	Errors []Error `xml:"error"`
+}
+
+// Error is a failure of a package which was not caused by a test, for example
+// a failure in TestMain, or a failed build.
+type Error struct {
+	Type    string  `xml:"type,attr"`
+	Name    string  `xml:"name,attr"`
+	Failure Failure `xml:"failure"`
+}
+
+// Collection is a group of tests. Each package has a single collection.
+type Collection struct {
+	Name    string `xml:"name,attr"`
+	Time    string `xml:"time,attr"`
+	Total   int    `xml:"total,attr"`
+	Passed  int    `xml:"passed,attr"`
+	Failed  int    `xml:"failed,attr"`
+	Skipped int    `xml:"skipped,attr"`
+	Tests   []Test `xml:"test"`
+}
+
+// Test is the result of a single test. xUnit.net does not support nested
+// tests, so subtests are reported as separate tests, with a method name that
+// includes the name of the parent test.
+type Test struct {
+	Name    string   `xml:"name,attr"`
+	Type    string   `xml:"type,attr"`
+	Method  string   `xml:"method,attr"`
+	Time    string   `xml:"time,attr"`
+	Result  string   `xml:"result,attr"`
+	Traits  *Traits  `xml:"traits,omitempty"`
+	Output  string   `xml:"output,omitempty"`
+	Reason  string   `xml:"reason,omitempty"`
+	Failure *Failure `xml:"failure,omitempty"`
+}
+
+// Traits is a wrapper for the <traits> tag as encoding/xml would otherwise
+// always create an empty one.
+type Traits struct {
+	Traits []Trait `xml:"trait"`
+}
+
+// Trait is a key/value pair attached to a test. The attributes emitted from
+// T.Attr are written as traits.
+type Trait struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+// Failure contains the message and output of a failed test.
+type Failure struct {
+	ExceptionType string `xml:"exception-type,attr"`
+	Message       string `xml:"message"`
+	StackTrace    string `xml:"stack-trace,omitempty"`
+}
+
+const (
+	resultPass = "Pass"
+	resultFail = "Fail"
+	resultSkip = "Skip"
+)
+
+// Config used to write an xUnit.net XML report.
+type Config struct {
+	// ToolVersion is the version of gotestsum.
+	ToolVersion string
+	// These are used for tests to have a consistent timestamp and elapsed time
+	customTimestamp time.Time
+	customElapsed   time.Duration
+}
+
+// Write creates an xUnit.net v2 XML document and writes it to out.
+func Write(out io.Writer, exec *testjson.Execution, cfg Config) error {
+	if err := write(out, generate(exec, cfg)); err != nil {
+		return fmt.Errorf("failed to write xUnit XML: %v", err)
+	}
+	return nil
+}
+
+func generate(exec *testjson.Execution, cfg Config) Assemblies {
+	start := exec.Started()
+	if !cfg.customTimestamp.IsZero() {
+		start = cfg.customTimestamp
+	}
+	framework := strings.TrimSpace("gotestsum " + cfg.ToolVersion)
+	doc := Assemblies{Timestamp: start.Format(time.RFC3339)}
+	for _, name := range exec.Packages() {
+		pkg := exec.Package(name)
+		elapsed := pkg.Elapsed()
+		if cfg.customElapsed != 0 {
+			elapsed = cfg.customElapsed
+		}
+		assembly := Assembly{
+			Name:          name,
+			TestFramework: framework,
+			RunDate:       start.Format("2006-01-02"),
+			RunTime:       start.Format("15:04:05"),
+			Time:          formatSeconds(elapsed),
+		}
+		if pkg.TestMainFailed() {
+			assembly.Errors = 1
+			assembly.ErrorList = &Errors{Errors: []Error{packageError(pkg)}}
+		}
+
+		collection := Collection{
+			Name: "Test collection for " + name,
+			Time: assembly.Time,
+		}
+		for _, r := range testresult.Last(pkg) {
+			test := newTest(pkg, r)
+			collection.Tests = append(collection.Tests, test)
+			collection.Total++
+			switch test.Result {
+			case resultPass:
+				collection.Passed++
+			case resultFail:
+				collection.Failed++
+			case resultSkip:
+				collection.Skipped++
+			}
+		}
+		assembly.Total = collection.Total
+		assembly.Passed = collection.Passed
+		assembly.Failed = collection.Failed
+		assembly.Skipped = collection.Skipped
+		assembly.Collections = []Collection{collection}
+		doc.Assemblies = append(doc.Assemblies, assembly)
+	}
+	return doc
+}
+
+func packageError(pkg *testjson.Package) Error {
+	lines := pkg.OutputLines(testjson.TestCase{})
+	return Error{
+		Type: "TestMain",
+		Name: "TestMain",
+		Failure: Failure{
+			ExceptionType: "TestMain",
+			Message:       testresult.FirstLine(lines, "Failed"),
+			StackTrace:    strings.Join(lines, ""),
+		},
+	}
+}
+
+// actionResults maps the action that ended a test to the xUnit.net result.
+var actionResults = map[testjson.Action]string{
+	testjson.ActionPass: resultPass,
+	testjson.ActionFail: resultFail,
+	testjson.ActionSkip: resultSkip,
+}
+
+func newTest(pkg *testjson.Package, r testresult.Attempt) Test {
+	test := Test{
+		Name:   r.Package + "." + r.Test.Name(),
+		Type:   r.Package,
+		Method: r.Test.Name(),
+		Time:   formatSeconds(r.Elapsed),
+		Result: actionResults[r.Action],
+		Traits: traits(r.Attributes),
+	}
+	lines := pkg.OutputLines(r.TestCase)
+	switch r.Action {
+	case testjson.ActionFail:
+		test.Failure = &Failure{
+			ExceptionType: "Failed",
+			Message:       testresult.FirstLine(lines, "Failed"),
+		}
+		test.Output = strings.Join(lines, "")
+	case testjson.ActionSkip:
+		test.Reason = testresult.FirstLine(lines, "Skipped")
+		test.Output = strings.Join(lines, "")
+	}
+	return test
+}
+
+func traits(attrs map[string]string) *Traits {
+	if len(attrs) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(attrs))
+	for key := range attrs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	t := &Traits{}
+	for _, key := range keys {
+		t.Traits = append(t.Traits, Trait{Name: key, Value: attrs[key]})
+	}
+	return t
+}
+
+func formatSeconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
+
+func write(out io.Writer, assemblies Assemblies) error {
+	doc, err := xml.MarshalIndent(assemblies, "", "\t")
+	if err != nil {
+		return err
+	}
+	_, err = out.Write([]byte(xml.Header))
+	if err != nil {
+		return err
+	}
+	_, err = out.Write(doc)
+	return err
+}
@@ -0,0 +1,99 @@
+package xunitxml
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"gotest.tools/gotestsum/internal/reporttest"
+	"gotest.tools/gotestsum/testjson"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/golden"
+)
+
+func TestWrite(t *testing.T) {
+	out := new(bytes.Buffer)
+	exec := reporttest.CreateExecution(t, testjson.ScanConfig{
+		Stdout: reporttest.ReadTestData(t, "go-test-json.out"),
+		Stderr: reporttest.ReadTestData(t, "go-test-json.err"),
+	})
+
+	err := Write(out, exec, testConfig())
+	assert.NilError(t, err)
+	golden.Assert(t, out.String(), "xunitxml-report.golden")
+}
+
+func TestGenerate_SubtestsAndTraits(t *testing.T) {
+	exec := reporttest.CreateExecution(t, testjson.ScanConfig{
+		Stdout: strings.NewReader(`{"Package": "pkg", "Test": "TestA", "Action": "run"}
+{"Package": "pkg", "Test": "TestA", "Action": "attr", "Key": "owner", "Value": "team-a"}
+{"Package": "pkg", "Test": "TestA", "Action": "attr", "Key": "issue", "Value": "1234"}
+{"Package": "pkg", "Test": "TestA/sub", "Action": "run"}
+{"Package": "pkg", "Test": "TestA/sub", "Action": "output", "Output": "    a_test.go:10: oops\n"}
+{"Package": "pkg", "Test": "TestA/sub", "Action": "fail"}
+{"Package": "pkg", "Test": "TestA", "Action": "fail"}
+{"Package": "pkg", "Test": "TestB", "Action": "run"}
+{"Package": "pkg", "Test": "TestB", "Action": "skip"}
+{"Package": "pkg", "Action": "fail"}
+`),
+	})
+
+	doc := generate(exec, testConfig())
+	assert.Equal(t, len(doc.Assemblies), 1)
+	assembly := doc.Assemblies[0]
+	assert.Equal(t, assembly.Total, 3)
+	assert.Equal(t, assembly.Failed, 2)
+	assert.Equal(t, assembly.Skipped, 1)
+
+	tests := assembly.Collections[0].Tests
+	assert.Equal(t, len(tests), 3)
+	// Subtests are flattened, with the full name as the method.
+	assert.Equal(t, tests[1].Method, "TestA/sub")
+	assert.Equal(t, tests[1].Result, resultFail)
+	assert.Equal(t, tests[1].Failure.Message, "a_test.go:10: oops")
+	assert.Assert(t, tests[1].Traits == nil)
+	// Traits are sorted by name.
+	assert.DeepEqual(t, tests[0].Traits, &Traits{Traits: []Trait{
+		{Name: "issue", Value: "1234"},
+		{Name: "owner", Value: "team-a"},
+	}})
+	assert.Equal(t, tests[2].Result, resultSkip)
+}
+
+func TestGenerate_ReportsTheLastAttempt(t *testing.T) {
+	exec := reporttest.NewExecution(t, `{"Package": "pkg", "Test": "TestFlaky", "Action": "run"}
+{"Package": "pkg", "Test": "TestFlaky", "Action": "output", "Output": "    flaky_test.go:8: timed out\n"}
+{"Package": "pkg", "Test": "TestFlaky", "Action": "fail"}
+{"Package": "pkg", "Test": "TestOK", "Action": "run"}
+{"Package": "pkg", "Test": "TestOK", "Action": "pass"}
+{"Package": "pkg", "Action": "fail"}
+`)
+	exec = reporttest.CreateExecution(t, testjson.ScanConfig{
+		RunID: 1,
+		Stdout: strings.NewReader(`{"Package": "pkg", "Test": "TestFlaky", "Action": "run"}
+{"Package": "pkg", "Test": "TestFlaky", "Action": "pass"}
+{"Package": "pkg", "Action": "pass"}
+`),
+		Execution: exec,
+	})
+
+	assembly := generate(exec, testConfig()).Assemblies[0]
+	assert.Equal(t, assembly.Total, 2)
+	assert.Equal(t, assembly.Passed, 2)
+	assert.Equal(t, assembly.Failed, 0)
+
+	tests := assembly.Collections[0].Tests
+	assert.Equal(t, tests[0].Method, "TestFlaky")
+	assert.Equal(t, tests[0].Result, resultPass)
+	assert.Assert(t, tests[0].Failure == nil)
+	assert.Equal(t, tests[1].Method, "TestOK")
+}
+
+func testConfig() Config {
+	return Config{
+		ToolVersion:     "v7.7.7",
+		customTimestamp: time.Date(2022, 6, 19, 13, 45, 0, 0, time.UTC),
+		customElapsed:   2 * time.Second,
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"os"
 
 	"gotest.tools/gotestsum/cmd"
+	"gotest.tools/gotestsum/cmd/tool/junit2json"
 	"gotest.tools/gotestsum/cmd/tool/matrix"
 	"gotest.tools/gotestsum/cmd/tool/slowest"
 	"gotest.tools/gotestsum/internal/log"
@@ -57,6 +58,7 @@ func toolRun(name string, args []string) error {
 Commands:
     %[1]s slowest      find or skip the slowest tests
     %[1]s ci-matrix    use previous test runtime to place packages into optimal buckets
+    %[1]s junit2json   convert JUnit XML files into 'go test -json' output
 
 Use '%[1]s COMMAND --help' for command specific help.
 `, name)
@@ -71,6 +73,8 @@ Use '%[1]s COMMAND --help' for command specific help.
 		return slowest.Run(name+" "+next, rest)
 	case "ci-matrix":
 		return matrix.Run(name+" "+next, rest)
+	case "junit2json":
+		return junit2json.Run(name+" "+next, rest)
 	default:
 		fmt.Fprintln(os.Stderr, usage(name))
 		return fmt.Errorf("invalid command: %v %v", name, next)
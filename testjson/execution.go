@@ -62,6 +62,10 @@ type TestEvent struct {
 	Key string
 	// Value for the attribute value
 	Value string
+	// FailedBuild is set on the package fail event when the test binary could
+	// not be built. It is the ImportPath of the build-output events for the
+	// package that failed to build.
+	FailedBuild string
 }
 
 // PackageEvent returns true if the event is a package start or end event
@@ -121,6 +125,16 @@ type Package struct {
 	// output caused by a test timeout. This is necessary to work around a race
 	// condition in test2json. See https://github.com/golang/go/issues/57305.
 	testTimeoutPanicInTest string
+	// timedOut is true if the package output contained the panic caused by
+	// reaching the test timeout.
+	timedOut bool
+	// failedBuild is the ImportPath of the package that failed to build, from
+	// the package fail event.
+	failedBuild string
+
+	// keepPassedOutput is true when the output of passed tests should be kept
+	// after the test ends.
+	keepPassedOutput bool
 }
 
 // Result returns if the package passed, failed, or was skipped because there
@@ -135,6 +149,13 @@ func (p *Package) Elapsed() time.Duration {
 	return p.elapsed
 }
 
+// Coverage returns the code coverage output for the package, without the
+// trailing newline (ex: coverage: 91.1% of statements). Coverage returns an
+// empty string if the package was not run with -cover.
+func (p *Package) Coverage() string {
+	return p.coverage
+}
+
 // TestCases returns all the test cases.
 func (p *Package) TestCases() []TestCase {
 	tc := append([]TestCase{}, p.Passed...)
@@ -209,6 +230,9 @@ func (p *Package) addOutput(id int, output string) {
 	if strings.HasPrefix(output, "WARNING: DATA RACE") {
 		p.hasDataRace = true
 	}
+	if strings.HasPrefix(output, "panic: test timed out") {
+		p.timedOut = true
+	}
 	p.output[id] = append(p.output[id], output)
 }
 
@@ -270,6 +294,54 @@ func (p *Package) TestMainFailed() bool {
 	return p.action == ActionFail && len(p.Failed) == 0
 }
 
+// AddAttribute adds an attribute to every run of the test. It is used to add
+// attributes to tests after they have run, in addition to the attributes
+// emitted from T.Attr.
+func (p *Package) AddAttribute(test TestName, key, value string) {
+	for _, tcs := range [][]TestCase{p.Passed, p.Failed, p.Skipped} {
+		for i := range tcs {
+			if tcs[i].Test == test {
+				tcs[i] = tcs[i].addAttribute(key, value)
+			}
+		}
+	}
+}
+
+// ShuffleSeed returns the seed used to shuffle the order of the tests in the
+// most recent run of the package, or an empty string if the tests were not
+// shuffled.
+func (p *Package) ShuffleSeed() string {
+	return strings.TrimPrefix(p.shuffleSeed, "-test.shuffle ")
+}
+
+// Panicked returns true if the package, or one of the tests in the package,
+// had output that looked like a panic.
+func (p *Package) Panicked() bool {
+	return p.panicked
+}
+
+// TimedOut returns true if the package was stopped because the test timeout
+// was reached.
+func (p *Package) TimedOut() bool {
+	return p.timedOut || p.testTimeoutPanicInTest != ""
+}
+
+// TimeoutTest returns the name of the test that was running when the test
+// timeout was reached, if the panic was attributed to a test. The panic output
+// is stored as package output (ID 0).
+func (p *Package) TimeoutTest() string {
+	return p.testTimeoutPanicInTest
+}
+
+// FailedBuild returns the ImportPath of the package that failed to build
+// when the test binary for the package could not be built. The output from
+// the build is available from Execution.BuildOutput. FailedBuild returns an
+// empty string if the package built, or if the version of Go does not report
+// build failures in the test2json output.
+func (p *Package) FailedBuild() string {
+	return p.failedBuild
+}
+
 // IsEmpty returns true if this package contains no tests.
 func (p *Package) IsEmpty() bool {
 	return p.Total == 0 && !p.TestMainFailed()
@@ -350,8 +422,24 @@ type TestCase struct {
 	Time time.Time
 	// Attributes are the attributes emitted from T.Attr.
 	Attributes map[string]string
+	// Pauses are the periods of time when the test was paused, waiting to run
+	// in parallel with other tests. Elapsed does not include these periods.
+	Pauses []Pause
+}
+
+// Pause is a period of time between a pause event and a cont event for a test.
+// End is zero if the test did not continue.
+type Pause struct {
+	Start time.Time
+	End   time.Time
 }
 
+// AttrQuarantined is the attribute added to the tests which are quarantined
+// by --quarantine-file. The failures of quarantined tests are printed in a
+// separate section of the summary. The key is prefixed with gotestsum so that
+// it does not conflict with the attributes emitted from T.Attr.
+const AttrQuarantined = "gotestsum.quarantined"
+
 // addAttribute adds an attribute with both key and value
 // and returns the updated TestCase with it.
 func (c TestCase) addAttribute(key string, value string) TestCase {
@@ -380,12 +468,17 @@ type Execution struct {
 	errors     []string
 	done       bool
 	lastRunID  int
+	// keepPassedOutput is copied to every new Package.
+	keepPassedOutput bool
+	// buildOutput is the output of build-output events, keyed by ImportPath.
+	buildOutput map[string][]string
 }
 
 func (e *Execution) add(event TestEvent) {
 	pkg, ok := e.packages[event.Package]
 	if !ok {
 		pkg = newPackage()
+		pkg.keepPassedOutput = e.keepPassedOutput
 		e.packages[event.Package] = pkg
 	}
 
@@ -402,6 +495,7 @@ func (e *Execution) add(event TestEvent) {
 	}
 
 	if event.Action == ActionBuild {
+		e.addBuildOutput(event)
 		e.addError(event.Output)
 		return
 	}
@@ -418,6 +512,9 @@ func (p *Package) addEvent(event TestEvent) {
 	case ActionPass, ActionFail:
 		p.action = event.Action
 		p.elapsed = elapsedDuration(event.Elapsed)
+		if event.FailedBuild != "" {
+			p.failedBuild = event.FailedBuild
+		}
 	case ActionOutput:
 		if coverage, ok := isCoverageOutput(event.Output); ok {
 			p.coverage = coverage
@@ -482,7 +579,14 @@ func (p *Package) addTestEvent(event TestEvent) {
 	case ActionAttr:
 		p.running[event.Test] = tc.addAttribute(event.Key, event.Value)
 		return
-	case ActionPause, ActionCont:
+	case ActionPause:
+		tc.Pauses = append(tc.Pauses, Pause{Start: event.Time})
+		p.running[event.Test] = tc
+		return
+	case ActionCont:
+		if n := len(tc.Pauses); n > 0 && tc.Pauses[n-1].End.IsZero() {
+			tc.Pauses[n-1].End = event.Time
+		}
 		return
 	}
 
@@ -510,7 +614,7 @@ func (p *Package) addTestEvent(event TestEvent) {
 		// Do not immediately remove output for subtests, to work around a bug
 		// in 'go test' where output is attributed to the wrong sub test.
 		// github.com/golang/go/issues/29755.
-		if tc.Test.IsSubTest() {
+		if tc.Test.IsSubTest() || p.keepPassedOutput {
 			return
 		}
 
@@ -674,6 +778,20 @@ func (e *Execution) addError(err string) {
 	e.errorsLock.Unlock()
 }
 
+func (e *Execution) addBuildOutput(event TestEvent) {
+	if e.buildOutput == nil {
+		e.buildOutput = make(map[string][]string)
+	}
+	e.buildOutput[event.ImportPath] = append(e.buildOutput[event.ImportPath], event.Output)
+}
+
+// BuildOutput returns the output from building the package with importPath.
+// The importPath for a test binary is the value returned by
+// Package.FailedBuild.
+func (e *Execution) BuildOutput(importPath string) string {
+	return strings.Join(e.buildOutput[importPath], "")
+}
+
 // Errors returns a list of all the errors.
 func (e *Execution) Errors() []string {
 	e.errorsLock.RLock()
@@ -746,6 +864,12 @@ type ScanConfig struct {
 	// IgnoreNonJSONOutputLines causes ScanTestOutput to ignore non-JSON lines received from
 	// the Stdout reader. Instead of causing an error, the lines will be sent to Handler.Err.
 	IgnoreNonJSONOutputLines bool
+	// KeepPassedOutput causes the output of tests that pass to be kept, so that
+	// it is available from Package.OutputLines after the test ends. By default
+	// the output of passed tests is removed to reduce memory use. Once set
+	// on an Execution the output of passed tests is kept for all subsequent
+	// scans.
+	KeepPassedOutput bool
 }
 
 // EventHandler is called by ScanTestOutput for each event and write to stderr.
@@ -781,6 +905,9 @@ func ScanTestOutput(config ScanConfig) (*Execution, error) {
 	}
 	execution.done = false
 	execution.lastRunID = config.RunID
+	if config.KeepPassedOutput {
+		execution.keepPassedOutput = true
+	}
 
 	var group errgroup.Group
 	group.Go(func() error {
@@ -153,10 +153,80 @@ func TestPackage_AddEvent(t *testing.T) {
 	}
 }
 
+func TestPackage_AddEvent_PauseAndCont(t *testing.T) {
+	start := time.Date(2022, 6, 19, 13, 45, 0, 0, time.UTC)
+	p := newPackage()
+	for _, event := range []TestEvent{
+		{Action: ActionRun, Test: "TestParallel", Time: start},
+		{Action: ActionPause, Test: "TestParallel", Time: start.Add(time.Millisecond)},
+		{Action: ActionCont, Test: "TestParallel", Time: start.Add(5 * time.Millisecond)},
+		{Action: ActionPass, Test: "TestParallel", Time: start.Add(7 * time.Millisecond), Elapsed: 0.003},
+	} {
+		p.addTestEvent(event)
+	}
+
+	assert.Equal(t, len(p.Passed), 1)
+	expected := []Pause{
+		{Start: start.Add(time.Millisecond), End: start.Add(5 * time.Millisecond)},
+	}
+	assert.DeepEqual(t, p.Passed[0].Pauses, expected)
+}
+
 func pkgOutput(id int, line string) map[int][]string {
 	return map[int][]string{id: {line}}
 }
 
+func TestScanTestOutput_KeepPassedOutput(t *testing.T) {
+	source := `{"Action":"run","Package":"example","Test":"TestPassed"}
+{"Action":"output","Package":"example","Test":"TestPassed","Output":"=== RUN   TestPassed\n"}
+{"Action":"output","Package":"example","Test":"TestPassed","Output":"    passed_test.go:10: some log\n"}
+{"Action":"output","Package":"example","Test":"TestPassed","Output":"--- PASS: TestPassed (0.00s)\n"}
+{"Action":"pass","Package":"example","Test":"TestPassed","Elapsed":0}
+{"Action":"pass","Package":"example","Elapsed":0.01}
+`
+	for _, keep := range []bool{true, false} {
+		t.Run(fmt.Sprintf("keep=%v", keep), func(t *testing.T) {
+			exec, err := ScanTestOutput(ScanConfig{
+				Stdout:           strings.NewReader(source),
+				KeepPassedOutput: keep,
+			})
+			assert.NilError(t, err)
+
+			pkg := exec.Package("example")
+			assert.Equal(t, len(pkg.Passed), 1)
+			var expected []string
+			if keep {
+				expected = []string{
+					"=== RUN   TestPassed\n",
+					"    passed_test.go:10: some log\n",
+					"--- PASS: TestPassed (0.00s)\n",
+				}
+			}
+			assert.DeepEqual(t, pkg.OutputLines(pkg.Passed[0]), expected, cmpopts.EquateEmpty())
+		})
+	}
+}
+
+func TestScanTestOutput_FailedBuild(t *testing.T) {
+	source := `{"ImportPath":"example.com/broken [example.com/broken.test]","Action":"build-output","Output":"# example.com/broken [example.com/broken.test]\n"}
+{"ImportPath":"example.com/broken [example.com/broken.test]","Action":"build-output","Output":"./broken.go:5:21: undefined: somepackage\n"}
+{"ImportPath":"example.com/broken [example.com/broken.test]","Action":"build-fail"}
+{"Action":"start","Package":"example.com/broken"}
+{"Action":"output","Package":"example.com/broken","Output":"FAIL\texample.com/broken [build failed]\n"}
+{"Action":"fail","Package":"example.com/broken","Elapsed":0,"FailedBuild":"example.com/broken [example.com/broken.test]"}
+`
+	exec, err := ScanTestOutput(ScanConfig{Stdout: strings.NewReader(source)})
+	assert.NilError(t, err)
+
+	pkg := exec.Package("example.com/broken")
+	assert.Equal(t, pkg.FailedBuild(), "example.com/broken [example.com/broken.test]")
+	expected := "# example.com/broken [example.com/broken.test]\n" +
+		"./broken.go:5:21: undefined: somepackage\n"
+	assert.Equal(t, exec.BuildOutput(pkg.FailedBuild()), expected)
+	assert.Assert(t, !pkg.TimedOut())
+	assert.Assert(t, !pkg.Panicked())
+}
+
 func TestScanTestOutput_WithMissingEvents(t *testing.T) {
 	source := golden.Get(t, "go-test-json-missing-test-events.out")
 	handler := &captureHandler{}
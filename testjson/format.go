@@ -5,8 +5,12 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path"
+	"regexp"
 	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/bitfield/gotestdox"
 	"github.com/fatih/color"
@@ -222,6 +226,53 @@ func isPkgFailureOutput(event TestEvent) bool {
 	)
 }
 
+// skipMessage returns the last line of output from a skipped test, which is
+// usually the message passed to t.Skip.
+func skipMessage(lines []string, testName string) string {
+	for i := len(lines) - 1; i >= 0; i-- {
+		line := strings.TrimSpace(lines[i])
+		if line == "" || isFramingLine(lines[i], testName) {
+			continue
+		}
+		return line
+	}
+	return ""
+}
+
+// testFileLocation is a reference to a line in a test file, found in the
+// output of a test. The go testing package prefixes the messages from t.Log and
+// t.Error with the file name and line number of the caller.
+type testFileLocation struct {
+	file    string
+	line    int
+	message string
+}
+
+// pathInPackage returns the path to the file, using RelativePackagePath for
+// the directory of the package.
+func (l testFileLocation) pathInPackage(pkg string) string {
+	return path.Join(RelativePackagePath(pkg), l.file)
+}
+
+var testFileLocationPattern = regexp.MustCompile(`^\s*([\w.\-]+_test\.go):(\d+): ?(.*)$`)
+
+// findTestFileLocation returns the first reference to a test file in the lines
+// of output.
+func findTestFileLocation(lines []string) (testFileLocation, bool) {
+	for _, line := range lines {
+		match := testFileLocationPattern.FindStringSubmatch(strings.TrimRight(line, "\n"))
+		if match == nil {
+			continue
+		}
+		num, err := strconv.Atoi(match[2])
+		if err != nil {
+			continue
+		}
+		return testFileLocation{file: match[1], line: num, message: match[3]}, true
+	}
+	return testFileLocation{}, false
+}
+
 func all(cond ...bool) bool {
 	for _, c := range cond {
 		if !c {
@@ -417,9 +468,15 @@ type FormatOptions struct {
 	HideEmptyPackages    bool
 	UseHiVisibilityIcons bool // Deprecated
 	Icons                string
+	// GitHubStepSummary enables the job summary written by the github-actions
+	// format.
+	GitHubStepSummary bool
 }
 
 // NewEventFormatter returns a formatter for printing events.
+//
+// Some formatters also implement io.Closer. Close must be called once all the
+// events have been formatted to write the end of the output.
 func NewEventFormatter(out io.Writer, format string, formatOpts FormatOptions) EventFormatter {
 	switch format {
 	case "none":
@@ -439,8 +496,13 @@ func NewEventFormatter(out io.Writer, format string, formatOpts FormatOptions) E
 	case "gotestdox", "testdox":
 		return testDoxFormat(out, formatOpts)
 	case "testname", "short-verbose":
-		if os.Getenv("GITHUB_ACTIONS") == "true" {
-			return githubActionsFormat(out)
+		switch {
+		case os.Getenv("GITHUB_ACTIONS") == "true":
+			return githubActionsFormat(out, formatOpts)
+		case strings.EqualFold(os.Getenv("TF_BUILD"), "true"):
+			return azurePipelinesFormat(out)
+		case os.Getenv("GITLAB_CI") == "true":
+			return gitlabFormat(out)
 		}
 		return testNameFormat(out)
 	case "pkgname", "short":
@@ -448,13 +510,153 @@ func NewEventFormatter(out io.Writer, format string, formatOpts FormatOptions) E
 	case "pkgname-and-test-fails", "short-with-failures":
 		return pkgNameWithFailuresFormat(out, formatOpts)
 	case "github-actions", "github-action":
-		return githubActionsFormat(out)
+		return githubActionsFormat(out, formatOpts)
+	case "azure-pipelines":
+		return azurePipelinesFormat(out)
+	case "gitlab-ci":
+		return gitlabFormat(out)
+	case "teamcity":
+		return teamcityFormat(out)
+	case "tap":
+		return newTAPFormatter(out)
+	case "tui":
+		return newTUIFormatter(out, formatOpts)
 	default:
 		return nil
 	}
 }
 
-func githubActionsFormat(out io.Writer) EventFormatter {
+// logGroups is the syntax used by a CI system to group lines of log output
+// into a collapsible section.
+type logGroups struct {
+	// start returns the text printed before the line that names the group.
+	start func(event TestEvent) string
+	// end returns the text printed after the last line of output in the group.
+	end func(event TestEvent) string
+	// annotate is called with the output of a failed test, after the group has
+	// ended. It may be nil.
+	annotate func(out io.StringWriter, event TestEvent, lines []string)
+}
+
+// githubActionsGroups uses the workflow commands described by
+// https://docs.github.com/en/actions/using-workflows/workflow-commands-for-github-actions
+var githubActionsGroups = logGroups{
+	start: func(TestEvent) string {
+		return "::group::"
+	},
+	end: func(TestEvent) string {
+		return "\n::endgroup::\n"
+	},
+	annotate: func(out io.StringWriter, event TestEvent, lines []string) {
+		name := joinPkgToTestName(RelativePackagePath(event.Package), event.Test)
+		props := "title=" + githubEscapeProperty("FAIL "+name)
+		if loc, ok := findTestFileLocation(lines); ok {
+			props = fmt.Sprintf("file=%s,line=%d,",
+				githubEscapeProperty(loc.pathInPackage(event.Package)), loc.line) + props
+		}
+		msg := strings.TrimSpace(strings.Join(lines, ""))
+		_, _ = out.WriteString("::error " + props + "::" + githubEscapeData(msg) + "\n")
+	},
+}
+
+var (
+	githubDataReplacer     = strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A")
+	githubPropertyReplacer = strings.NewReplacer(
+		"%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C")
+)
+
+func githubEscapeData(s string) string {
+	return githubDataReplacer.Replace(s)
+}
+
+func githubEscapeProperty(s string) string {
+	return githubPropertyReplacer.Replace(s)
+}
+
+// azurePipelinesGroups uses the logging commands described by
+// https://learn.microsoft.com/en-us/azure/devops/pipelines/scripts/logging-commands
+var azurePipelinesGroups = logGroups{
+	start: func(TestEvent) string {
+		return "##[group]"
+	},
+	end: func(TestEvent) string {
+		return "\n##[endgroup]\n"
+	},
+	annotate: func(out io.StringWriter, event TestEvent, lines []string) {
+		props := "type=error"
+		msg := joinPkgToTestName(RelativePackagePath(event.Package), event.Test)
+		if loc, ok := findTestFileLocation(lines); ok {
+			props += ";sourcepath=" + azureEscapeProperty(loc.pathInPackage(event.Package))
+			props += fmt.Sprintf(";linenumber=%d", loc.line)
+			msg += ": " + loc.message
+		}
+		_, _ = out.WriteString("##vso[task.logissue " + props + "]" + azureEscapeData(msg) + "\n")
+	},
+}
+
+func azurePipelinesFormat(out io.Writer) EventFormatter {
+	return logGroupFormat(out, azurePipelinesGroups)
+}
+
+var (
+	azureDataReplacer     = strings.NewReplacer("%", "%AZP25", "\r", "%0D", "\n", "%0A")
+	azurePropertyReplacer = strings.NewReplacer(
+		"%", "%AZP25", "\r", "%0D", "\n", "%0A", ";", "%3B", "]", "%5D")
+)
+
+func azureEscapeData(s string) string {
+	return azureDataReplacer.Replace(s)
+}
+
+func azureEscapeProperty(s string) string {
+	return azurePropertyReplacer.Replace(s)
+}
+
+// gitlabGroups uses the collapsible sections described by
+// https://docs.gitlab.com/ee/ci/jobs/job_logs.html#custom-collapsible-sections
+func gitlabGroups() logGroups {
+	var count int
+	var section string
+	return logGroups{
+		start: func(event TestEvent) string {
+			count++
+			section = gitlabSectionName(count, event)
+			start := eventTime(event).Add(-elapsedDuration(event.Elapsed))
+			return fmt.Sprintf("\x1b[0Ksection_start:%d:%s[collapsed=true]\r\x1b[0K",
+				start.Unix(), section)
+		},
+		end: func(event TestEvent) string {
+			return fmt.Sprintf("\x1b[0Ksection_end:%d:%s\r\x1b[0K\n",
+				eventTime(event).Unix(), section)
+		},
+	}
+}
+
+func gitlabFormat(out io.Writer) EventFormatter {
+	return logGroupFormat(out, gitlabGroups())
+}
+
+var gitlabSectionNameReplacer = regexp.MustCompile(`[^a-zA-Z0-9_.-]`)
+
+// gitlabSectionName returns a unique section name for a test. Section names
+// may only contain letters, numbers, and the _ . - characters.
+func gitlabSectionName(count int, event TestEvent) string {
+	name := joinPkgToTestName(RelativePackagePath(event.Package), event.Test)
+	return fmt.Sprintf("test_%d_%s", count, gitlabSectionNameReplacer.ReplaceAllString(name, "_"))
+}
+
+// eventTime returns the time of the event, or the current time for artificial
+// events that have no time.
+func eventTime(event TestEvent) time.Time {
+	if event.Time.IsZero() {
+		return timeNow()
+	}
+	return event.Time
+}
+
+// logGroupFormat prints a line for each test and package, like testNameFormat,
+// and groups the output of each test using the syntax of a CI system.
+func logGroupFormat(out io.Writer, groups logGroups) EventFormatter {
 	buf := bufio.NewWriter(out)
 
 	type name struct {
@@ -477,7 +679,7 @@ func githubActionsFormat(out io.Writer) EventFormatter {
 		// test case end event
 		if event.Test != "" && event.Action.IsTerminal() {
 			if len(output[key]) > 0 {
-				buf.WriteString("::group::")
+				buf.WriteString(groups.start(event))
 			} else {
 				buf.WriteString("  ")
 			}
@@ -487,7 +689,12 @@ func githubActionsFormat(out io.Writer) EventFormatter {
 				buf.WriteString(item)
 			}
 			if len(output[key]) > 0 {
-				buf.WriteString("\n::endgroup::\n")
+				buf.WriteString(groups.end(event))
+			}
+			// Root tests that failed because of a subtest failure have no
+			// output, and the subtest is already annotated.
+			if event.Action == ActionFail && groups.annotate != nil && len(output[key]) > 0 {
+				groups.annotate(buf, event, output[key])
 			}
 			delete(output, key)
 			return buf.Flush()
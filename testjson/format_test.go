@@ -168,10 +168,27 @@ func TestFormats_DefaultGoTestJson(t *testing.T) {
 			expectedOut: "input/go-test-json.out",
 		},
 		{
-			name:        "github-actions",
-			format:      githubActionsFormat,
+			name: "github-actions",
+			format: func(out io.Writer) EventFormatter {
+				return githubActionsFormat(out, FormatOptions{})
+			},
 			expectedOut: "format/github-actions.out",
 		},
+		{
+			name:        "azure-pipelines",
+			format:      azurePipelinesFormat,
+			expectedOut: "format/azure-pipelines.out",
+		},
+		{
+			name:        "gitlab-ci",
+			format:      gitlabFormat,
+			expectedOut: "format/gitlab-ci.out",
+		},
+		{
+			name:        "teamcity",
+			format:      teamcityFormat,
+			expectedOut: "format/teamcity.out",
+		},
 	}
 
 	for _, tc := range testCases {
@@ -323,3 +340,19 @@ func TestFormats_Shuffle(t *testing.T) {
 		})
 	}
 }
+
+func TestFindTestFileLocation(t *testing.T) {
+	lines := []string{
+		"=== RUN   TestFailed\n",
+		"this is a Print\n",
+		"    fails_test.go:34: this failed\n",
+		"    fails_test.go:35: this also failed\n",
+	}
+	loc, ok := findTestFileLocation(lines)
+	assert.Assert(t, ok)
+	expected := testFileLocation{file: "fails_test.go", line: 34, message: "this failed"}
+	assert.Equal(t, loc, expected)
+
+	_, ok = findTestFileLocation([]string{"fails.go:12: not a test file\n"})
+	assert.Assert(t, !ok)
+}
@@ -0,0 +1,142 @@
+package testjson
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// githubActionsFormatter wraps the github-actions log group format. When all
+// the events have been formatted, Close appends a Markdown summary of the
+// Execution to the file named by the GITHUB_STEP_SUMMARY environment variable.
+type githubActionsFormatter struct {
+	EventFormatter
+	exec *Execution
+}
+
+// githubActionsFormat returns the github-actions log group format. The job
+// summary is only written when opts.GitHubStepSummary is true, because the
+// format is used by default in GitHub Actions.
+func githubActionsFormat(out io.Writer, opts FormatOptions) EventFormatter {
+	format := logGroupFormat(out, githubActionsGroups)
+	if !opts.GitHubStepSummary {
+		return format
+	}
+	return &githubActionsFormatter{EventFormatter: format}
+}
+
+func (f *githubActionsFormatter) Format(event TestEvent, exec *Execution) error {
+	f.exec = exec
+	return f.EventFormatter.Format(event, exec)
+}
+
+// Close writes the job summary, if GITHUB_STEP_SUMMARY is set.
+func (f *githubActionsFormatter) Close() error {
+	filename := os.Getenv("GITHUB_STEP_SUMMARY")
+	if filename == "" || f.exec == nil {
+		return nil
+	}
+	fh, err := os.OpenFile(filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open step summary file: %w", err)
+	}
+	if err := writeGitHubStepSummary(fh, f.exec); err != nil {
+		_ = fh.Close()
+		return fmt.Errorf("failed to write step summary: %w", err)
+	}
+	return fh.Close()
+}
+
+// maxSlowestInStepSummary is the number of tests listed in the slowest tests
+// section of the step summary.
+const maxSlowestInStepSummary = 10
+
+// writeGitHubStepSummary writes a Markdown summary of the packages, failed tests,
+// skipped tests, and slowest tests in the execution.
+func writeGitHubStepSummary(out io.Writer, exec *Execution) error {
+	buf := bufio.NewWriter(out)
+
+	buf.WriteString("### Test results\n\n")
+	fmt.Fprintf(buf, "%d tests%s%s%s in %s\n\n",
+		exec.Total(),
+		formatTestCount(len(exec.Skipped()), "skipped", ""),
+		formatTestCount(len(exec.Failed()), "failure", "s"),
+		formatTestCount(countErrors(exec.Errors()), "error", "s"),
+		FormatDurationAsSeconds(exec.Elapsed(), 3))
+
+	buf.WriteString("| Package | Result | Passed | Failed | Skipped | Elapsed |\n")
+	buf.WriteString("| --- | --- | ---: | ---: | ---: | ---: |\n")
+	for _, name := range exec.Packages() {
+		pkg := exec.Package(name)
+		fmt.Fprintf(buf, "| %s | %s | %d | %d | %d | %s |\n",
+			markdownCode(RelativePackagePath(name)),
+			pkgResult(pkg),
+			len(pkg.Passed),
+			len(pkg.Failed),
+			len(pkg.Skipped),
+			FormatDurationAsSeconds(pkg.Elapsed(), 3))
+	}
+
+	writeStepSummaryTestCases(buf, "Failed tests", exec.Failed())
+	writeStepSummaryTestCases(buf, "Skipped tests", exec.Skipped())
+	writeStepSummaryTestCases(buf, "Slowest tests", slowestTestCases(exec, maxSlowestInStepSummary))
+	return buf.Flush()
+}
+
+func pkgResult(pkg *Package) string {
+	switch {
+	case pkg.Result() == ActionFail:
+		return "FAIL"
+	case pkg.Total == 0:
+		return "EMPTY"
+	default:
+		return "PASS"
+	}
+}
+
+func writeStepSummaryTestCases(out io.Writer, header string, tcs []TestCase) {
+	if len(tcs) == 0 {
+		return
+	}
+	fmt.Fprintf(out, "\n#### %s\n\n", header)
+	fmt.Fprintln(out, "| Test | Elapsed |")
+	fmt.Fprintln(out, "| --- | ---: |")
+	for _, tc := range tcs {
+		name := tc.Test.Name()
+		if name == "" {
+			name = "TestMain"
+		}
+		fmt.Fprintf(out, "| %s%s | %s |\n",
+			markdownCode(joinPkgToTestName(RelativePackagePath(tc.Package), name)),
+			formatRunID(tc.RunID),
+			FormatDurationAsSeconds(tc.Elapsed, 2))
+	}
+}
+
+// slowestTestCases returns at most num test cases with the longest elapsed
+// time, sorted from slowest to fastest.
+func slowestTestCases(exec *Execution, num int) []TestCase {
+	var tcs []TestCase
+	for _, name := range exec.Packages() {
+		for _, tc := range exec.Package(name).TestCases() {
+			if tc.Elapsed > 0 {
+				tcs = append(tcs, tc)
+			}
+		}
+	}
+	sort.SliceStable(tcs, func(i, j int) bool {
+		return tcs[i].Elapsed > tcs[j].Elapsed
+	})
+	if len(tcs) > num {
+		tcs = tcs[:num]
+	}
+	return tcs
+}
+
+// markdownCode formats s as inline code that is safe to use in a table cell.
+func markdownCode(s string) string {
+	return "`" + strings.ReplaceAll(s, "|", `\|`) + "`"
+}
@@ -0,0 +1,51 @@
+package testjson
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/golden"
+)
+
+func TestWriteGitHubStepSummary(t *testing.T) {
+	shim := newFakeHandler(noopFormatter{}, "input/go-test-json")
+	exec, err := ScanTestOutput(shim.Config(t))
+	assert.NilError(t, err)
+
+	out := new(bytes.Buffer)
+	assert.NilError(t, writeGitHubStepSummary(out, exec))
+	golden.Assert(t, out.String(), "format/github-step-summary.md")
+}
+
+func TestGitHubActionsFormatter_Close(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "summary.md")
+	assert.NilError(t, os.WriteFile(filename, []byte("previous step\n"), 0o644))
+	t.Setenv("GITHUB_STEP_SUMMARY", filename)
+
+	opts := FormatOptions{GitHubStepSummary: true}
+	formatter := githubActionsFormat(new(bytes.Buffer), opts).(*githubActionsFormatter)
+	shim := newFakeHandler(formatter, "input/go-test-json")
+	_, err := ScanTestOutput(shim.Config(t))
+	assert.NilError(t, err)
+	assert.NilError(t, formatter.Close())
+
+	raw, err := os.ReadFile(filename)
+	assert.NilError(t, err)
+	expected := "previous step\n" + string(golden.Get(t, "format/github-step-summary.md"))
+	assert.Equal(t, string(raw), expected)
+}
+
+func TestGitHubActionsFormat_StepSummaryDisabledByDefault(t *testing.T) {
+	_, ok := githubActionsFormat(new(bytes.Buffer), FormatOptions{}).(io.Closer)
+	assert.Assert(t, !ok, "expected no step summary")
+}
+
+type noopFormatter struct{}
+
+func (noopFormatter) Format(TestEvent, *Execution) error {
+	return nil
+}
@@ -73,6 +73,7 @@ func PrintSummary(out io.Writer, execution *Execution, opts Summary) {
 	}
 	if opts.Includes(SummarizeFailed) {
 		writeTestCaseSummary(out, execSummary, formatFailed())
+		writeTestCaseSummary(out, execSummary, formatQuarantined())
 	}
 
 	errors := execution.Errors()
@@ -80,11 +81,13 @@ func PrintSummary(out io.Writer, execution *Execution, opts Summary) {
 		writeErrorSummary(out, errors)
 	}
 
-	fmt.Fprintf(out, "\n%s %d tests%s%s%s in %s\n",
+	failed, quarantined := splitQuarantined(execution.Failed())
+	fmt.Fprintf(out, "\n%s %d tests%s%s%s%s in %s\n",
 		formatExecStatus(execution),
 		execution.Total(),
 		formatTestCount(len(execution.Skipped()), "skipped", ""),
-		formatTestCount(len(execution.Failed()), "failure", "s"),
+		formatTestCount(len(failed), "failure", "s"),
+		formatTestCount(len(quarantined), "quarantined", ""),
 		formatTestCount(countErrors(errors), "error", "s"),
 		FormatDurationAsSeconds(execution.Elapsed(), 3))
 }
@@ -200,11 +203,37 @@ func formatFailed() testCaseFormatConfig {
 		header: withColor("Failed"),
 		prefix: withColor("FAIL"),
 		getter: func(execution executionSummary) []TestCase {
-			return execution.Failed()
+			failed, _ := splitQuarantined(execution.Failed())
+			return failed
 		},
 	}
 }
 
+func formatQuarantined() testCaseFormatConfig {
+	withColor := color.CyanString
+	return testCaseFormatConfig{
+		header: withColor("Quarantined"),
+		prefix: withColor("QUARANTINED"),
+		getter: func(execution executionSummary) []TestCase {
+			_, quarantined := splitQuarantined(execution.Failed())
+			return quarantined
+		},
+	}
+}
+
+// splitQuarantined returns the test cases without the AttrQuarantined
+// attribute, followed by the test cases with the attribute.
+func splitQuarantined(tcs []TestCase) (other []TestCase, quarantined []TestCase) {
+	for _, tc := range tcs {
+		if _, ok := tc.Attributes[AttrQuarantined]; ok {
+			quarantined = append(quarantined, tc)
+			continue
+		}
+		other = append(other, tc)
+	}
+	return other, quarantined
+}
+
 func formatSkipped() testCaseFormatConfig {
 	withColor := color.YellowString
 	return testCaseFormatConfig{
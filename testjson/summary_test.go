@@ -217,6 +217,53 @@ DONE 13 tests, 1 skipped, 4 failures, 1 error in 34.123s
 	})
 }
 
+func TestPrintSummary_WithQuarantinedFailures(t *testing.T) {
+	patchPkgPathPrefix(t, "example.com")
+	start := time.Date(2022, 1, 2, 3, 4, 5, 600, time.UTC)
+	patchTimeNow(t, start.Add(2*time.Second))
+
+	exec := &Execution{
+		testStart: start,
+		done:      true,
+		packages: map[string]*Package{
+			"example.com/project/fs": {
+				Total: 3,
+				Failed: []TestCase{
+					{
+						Package: "example.com/project/fs",
+						Test:    "TestFileDo",
+						Elapsed: 1411 * time.Millisecond,
+						ID:      1,
+					},
+					{
+						Package:    "example.com/project/fs",
+						Test:       "TestFlaky",
+						Elapsed:    12 * time.Millisecond,
+						ID:         2,
+						Attributes: map[string]string{AttrQuarantined: "sometimes slow"},
+					},
+				},
+				output: map[int][]string{},
+				action: ActionFail,
+			},
+		},
+	}
+
+	out := new(bytes.Buffer)
+	PrintSummary(out, exec, SummarizeAll-SummarizeOutput)
+
+	expected := `
+=== Failed
+=== FAIL: project/fs TestFileDo (1.41s)
+
+=== Quarantined
+=== QUARANTINED: project/fs TestFlaky (0.01s)
+
+DONE 3 tests, 1 failure, 1 quarantined in 2.000s
+`
+	assert.Equal(t, out.String(), expected)
+}
+
 func patchTimeNow(t *testing.T, to time.Time) {
 	timeNow = func() time.Time {
 		return to
@@ -0,0 +1,249 @@
+package testjson
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// tapFormatter prints a TAP version 14 document (https://testanything.org/tap-version-14-specification.html).
+//
+// Every package is a test point in the top level of the document. The tests
+// in a package are printed as a subtest block of the package test point, and
+// subtests are nested in a subtest block of their parent test. Test points
+// can only be printed once all of their subtests have finished, so the test
+// points for a package are buffered until the package ends.
+type tapFormatter struct {
+	out     *bufio.Writer
+	started bool
+	count   int
+	pkgs    map[string]*tapPackage
+}
+
+// tapPackage stores the test points of a package that have not been printed.
+type tapPackage struct {
+	// blocks maps the name of a test to the test points of its subtests. The
+	// test points of root tests are stored with the empty string key.
+	blocks map[string]*tapBlock
+}
+
+// tapBlock is a sequence of test points, and the comments and YAML blocks
+// that belong to them.
+type tapBlock struct {
+	count int
+	buf   strings.Builder
+}
+
+func newTAPFormatter(out io.Writer) *tapFormatter {
+	return &tapFormatter{
+		out:  bufio.NewWriter(out),
+		pkgs: make(map[string]*tapPackage),
+	}
+}
+
+func (f *tapFormatter) Format(event TestEvent, exec *Execution) error {
+	if !f.started {
+		f.out.WriteString("TAP version 14\n")
+		f.started = true
+	}
+
+	switch {
+	case event.PackageEvent():
+		if !event.Action.IsTerminal() {
+			return f.out.Flush()
+		}
+		f.writePackage(event, exec.Package(event.Package))
+	case event.Action.IsTerminal():
+		f.addTest(event, exec.Package(event.Package))
+	}
+	return f.out.Flush()
+}
+
+func (f *tapFormatter) pkg(name string) *tapPackage {
+	pkg, ok := f.pkgs[name]
+	if !ok {
+		pkg = &tapPackage{blocks: make(map[string]*tapBlock)}
+		f.pkgs[name] = pkg
+	}
+	return pkg
+}
+
+func (p *tapPackage) block(name string) *tapBlock {
+	block, ok := p.blocks[name]
+	if !ok {
+		block = &tapBlock{}
+		p.blocks[name] = block
+	}
+	return block
+}
+
+func (f *tapFormatter) addTest(event TestEvent, pkg *Package) {
+	tapPkg := f.pkg(event.Package)
+	name := TestName(event.Test)
+	parent := tapPkg.block(name.Parent())
+
+	if children, ok := tapPkg.blocks[name.Name()]; ok {
+		writeTAPSubtest(&parent.buf, name.Name(), children)
+		delete(tapPkg.blocks, name.Name())
+	}
+
+	parent.count++
+	desc := name.Name() + formatRunID(event.RunID)
+	switch event.Action {
+	case ActionPass:
+		writeTAPTestPoint(&parent.buf, true, parent.count, desc, "")
+	case ActionSkip:
+		tc := lastSkippedByName(pkg, event.Test)
+		writeTAPTestPoint(&parent.buf, true, parent.count, desc,
+			tapSkipDirective(skipMessage(pkg.OutputLines(tc), event.Test)))
+	case ActionFail:
+		tc := pkg.LastFailedByName(event.Test)
+		writeTAPTestPoint(&parent.buf, false, parent.count, desc, "")
+		writeTAPDiagnostic(&parent.buf, tc, pkg.OutputLines(tc))
+	}
+}
+
+func (f *tapFormatter) writePackage(event TestEvent, pkg *Package) {
+	tapPkg := f.pkg(event.Package)
+	delete(f.pkgs, event.Package)
+
+	f.count++
+	desc := event.Package + formatRunID(event.RunID)
+	if block, ok := tapPkg.blocks[""]; ok {
+		writeTAPSubtest(f.out, event.Package, block)
+	}
+
+	switch {
+	case event.Action == ActionFail:
+		writeTAPTestPoint(f.out, false, f.count, desc, "")
+		if pkg.TestMainFailed() {
+			tc := TestCase{Package: event.Package}
+			writeTAPDiagnostic(f.out, tc, pkg.OutputLines(tc))
+		}
+	case event.Action == ActionSkip || pkg.Total == 0:
+		writeTAPTestPoint(f.out, true, f.count, desc, "SKIP no tests to run")
+	default:
+		writeTAPTestPoint(f.out, true, f.count, desc, "")
+	}
+}
+
+// Close writes the plan for the document. Any tests that were not followed by
+// a package end event (ex: tests that never finished because of a timeout)
+// are written as a failed package before the plan.
+func (f *tapFormatter) Close() error {
+	if !f.started {
+		f.out.WriteString("TAP version 14\n")
+	}
+	names := make([]string, 0, len(f.pkgs))
+	for name := range f.pkgs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		block, ok := f.pkgs[name].blocks[""]
+		if !ok {
+			continue
+		}
+		f.count++
+		writeTAPSubtest(f.out, name, block)
+		writeTAPTestPoint(f.out, false, f.count, name, "")
+	}
+	fmt.Fprintf(f.out, "1..%d\n", f.count)
+	return f.out.Flush()
+}
+
+func writeTAPSubtest(out io.StringWriter, name string, block *tapBlock) {
+	_, _ = out.WriteString("# Subtest: " + tapEscape(name) + "\n")
+	_, _ = out.WriteString(indentLines(block.buf.String(), "    "))
+	_, _ = out.WriteString(fmt.Sprintf("    1..%d\n", block.count))
+}
+
+func writeTAPTestPoint(out io.StringWriter, ok bool, num int, desc string, directive string) {
+	var sb strings.Builder
+	if !ok {
+		sb.WriteString("not ")
+	}
+	sb.WriteString(fmt.Sprintf("ok %d - %s", num, tapEscape(desc)))
+	if directive != "" {
+		sb.WriteString(" # " + directive)
+	}
+	sb.WriteString("\n")
+	_, _ = out.WriteString(sb.String())
+}
+
+// writeTAPDiagnostic writes a YAML diagnostic block with the elapsed time and
+// output of a failed test.
+func writeTAPDiagnostic(out io.StringWriter, tc TestCase, lines []string) {
+	var sb strings.Builder
+	sb.WriteString("  ---\n")
+	if tc.Elapsed >= 0 {
+		sb.WriteString(fmt.Sprintf("  duration_ms: %d\n", tc.Elapsed.Milliseconds()))
+	}
+
+	var output strings.Builder
+	for _, line := range lines {
+		if isFramingLine(line, tc.Test.Name()) {
+			continue
+		}
+		output.WriteString(line)
+		if !strings.HasSuffix(line, "\n") {
+			output.WriteString("\n")
+		}
+	}
+	if output.Len() > 0 {
+		// The indentation indicator is required because go test output lines
+		// often start with whitespace.
+		sb.WriteString("  output: |2\n")
+		sb.WriteString(indentLines(output.String(), "    "))
+	}
+	sb.WriteString("  ...\n")
+	_, _ = out.WriteString(sb.String())
+}
+
+// tapSkipDirective returns the SKIP directive for a skipped test. The file
+// and line prefix added by t.Skip is removed from the reason.
+func tapSkipDirective(msg string) string {
+	if match := testFileLocationPattern.FindStringSubmatch(msg); match != nil {
+		msg = match[3]
+	}
+	if msg == "" {
+		return "SKIP"
+	}
+	return "SKIP " + tapEscape(msg)
+}
+
+func lastSkippedByName(pkg *Package, name string) TestCase {
+	for i := len(pkg.Skipped) - 1; i >= 0; i-- {
+		if pkg.Skipped[i].Test.Name() == name {
+			return pkg.Skipped[i]
+		}
+	}
+	return TestCase{}
+}
+
+// tapEscape escapes the characters which have a special meaning in the
+// description of a test point.
+func tapEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "#", `\#`, "\n", " ").Replace(s)
+}
+
+func indentLines(s string, indent string) string {
+	if s == "" {
+		return ""
+	}
+	lines := strings.SplitAfter(s, "\n")
+	var sb strings.Builder
+	for _, line := range lines {
+		if line == "" {
+			continue
+		}
+		if line != "\n" {
+			sb.WriteString(indent)
+		}
+		sb.WriteString(line)
+	}
+	return sb.String()
+}
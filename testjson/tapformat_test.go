@@ -0,0 +1,61 @@
+package testjson
+
+import (
+	"bytes"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/golden"
+)
+
+func TestTAPFormat(t *testing.T) {
+	type testCase struct {
+		name        string
+		input       string
+		expectedOut string
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		out := new(bytes.Buffer)
+		formatter := newTAPFormatter(out)
+		shim := newFakeHandler(formatter, tc.input)
+		_, err := ScanTestOutput(shim.Config(t))
+		assert.NilError(t, err)
+		assert.NilError(t, formatter.Close())
+
+		golden.Assert(t, out.String(), tc.expectedOut)
+	}
+
+	testCases := []testCase{
+		{
+			name:        "default",
+			input:       "input/go-test-json",
+			expectedOut: "format/tap.out",
+		},
+		{
+			name:        "with shuffle",
+			input:       "input/go-test-json-with-shuffle",
+			expectedOut: "format/tap-shuffle.out",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
+
+func TestTAPFormat_EmptyInput(t *testing.T) {
+	out := new(bytes.Buffer)
+	formatter := newTAPFormatter(out)
+	assert.NilError(t, formatter.Close())
+	assert.Equal(t, out.String(), "TAP version 14\n1..0\n")
+}
+
+func TestTAPSkipDirective(t *testing.T) {
+	assert.Equal(t, tapSkipDirective("good_test.go:27: the skip message"), "SKIP the skip message")
+	assert.Equal(t, tapSkipDirective("good_test.go:23:"), "SKIP")
+	assert.Equal(t, tapSkipDirective("no # location"), `SKIP no \# location`)
+	assert.Equal(t, tapSkipDirective(""), "SKIP")
+}
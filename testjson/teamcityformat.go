@@ -0,0 +1,175 @@
+package testjson
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// teamcityFormat prints TeamCity service messages
+// (https://www.jetbrains.com/help/teamcity/service-messages.html) for each
+// package and test as the events are received. The flowId of the suite messages
+// is the package name, and each test has its own flow, started with the package
+// flow as the parent, so that messages from tests and packages that run in
+// parallel can be attributed to the correct test and suite.
+func teamcityFormat(out io.Writer) EventFormatter {
+	buf := bufio.NewWriter(out)
+
+	type name struct {
+		Package string
+		Test    string
+	}
+	output := map[name][]string{}
+	started := map[string]bool{}
+	flows := map[name]bool{}
+	startFlow := func(key name, event TestEvent) {
+		if !flows[key] {
+			flows[key] = true
+			writeTeamCityMessage(buf, "flowStarted", event,
+				teamcityAttr{"parent", event.Package})
+		}
+	}
+
+	return eventFormatterFunc(func(event TestEvent, exec *Execution) error {
+		pkgName := event.Package
+		// events that do not belong to a package, like build output, can not
+		// be attributed to a suite.
+		if pkgName == "" {
+			return nil
+		}
+		if !started[pkgName] {
+			started[pkgName] = true
+			// the suite may be started by a test event, so the message is
+			// written with a package event to use the flow of the package.
+			writeTeamCityMessage(buf, "testSuiteStarted", TestEvent{Time: event.Time, Package: pkgName},
+				teamcityAttr{"name", pkgName})
+		}
+
+		key := name{Package: event.Package, Test: event.Test}
+		switch {
+		case event.PackageEvent():
+			if !event.Action.IsTerminal() {
+				return nil
+			}
+			pkg := exec.Package(pkgName)
+			if pkg.TestMainFailed() {
+				writeTeamCityTestMainFailure(buf, event, pkg)
+			}
+			writeTeamCityMessage(buf, "testSuiteFinished", event,
+				teamcityAttr{"name", pkgName})
+			delete(started, pkgName)
+			return buf.Flush()
+
+		case event.Action == ActionRun:
+			startFlow(key, event)
+			writeTeamCityMessage(buf, "testStarted", event,
+				teamcityAttr{"name", event.Test},
+				teamcityAttr{"captureStandardOutput", "false"})
+			return buf.Flush()
+
+		case event.Action == ActionOutput:
+			if !isFramingLine(event.Output, event.Test) {
+				output[key] = append(output[key], event.Output)
+			}
+			return nil
+
+		case event.Action.IsTerminal():
+			lines := output[key]
+			delete(output, key)
+			startFlow(key, event)
+
+			testName := teamcityAttr{"name", event.Test}
+			switch event.Action {
+			case ActionFail:
+				writeTeamCityMessage(buf, "testFailed", event, testName,
+					teamcityAttr{"message", "Failed"},
+					teamcityAttr{"details", strings.Join(lines, "")})
+			case ActionSkip:
+				writeTeamCityMessage(buf, "testIgnored", event, testName,
+					teamcityAttr{"message", skipMessage(lines, event.Test)})
+			default:
+				if len(lines) > 0 {
+					writeTeamCityMessage(buf, "testStdOut", event, testName,
+						teamcityAttr{"out", strings.Join(lines, "")})
+				}
+			}
+			writeTeamCityMessage(buf, "testFinished", event, testName,
+				teamcityAttr{"duration", teamcityDuration(event.Elapsed)})
+			writeTeamCityMessage(buf, "flowFinished", event)
+			delete(flows, key)
+			return buf.Flush()
+		}
+		return nil
+	})
+}
+
+// writeTeamCityTestMainFailure reports the package output as a failed TestMain
+// test, because TeamCity has no way to attach a failure to a suite.
+func writeTeamCityTestMainFailure(out io.Writer, event TestEvent, pkg *Package) {
+	var details strings.Builder
+	_ = pkg.WriteOutputTo(&details, 0)
+
+	testName := teamcityAttr{"name", "TestMain"}
+	writeTeamCityMessage(out, "testStarted", event, testName)
+	writeTeamCityMessage(out, "testFailed", event, testName,
+		teamcityAttr{"message", "Failed"},
+		teamcityAttr{"details", details.String()})
+	writeTeamCityMessage(out, "testFinished", event, testName)
+}
+
+type teamcityAttr struct {
+	name  string
+	value string
+}
+
+func writeTeamCityMessage(out io.Writer, msgType string, event TestEvent, attrs ...teamcityAttr) {
+	var sb strings.Builder
+	sb.WriteString("##teamcity[" + msgType)
+	for _, attr := range attrs {
+		sb.WriteString(fmt.Sprintf(" %s='%s'", attr.name, teamcityEscape(attr.value)))
+	}
+	if !event.Time.IsZero() {
+		sb.WriteString(fmt.Sprintf(" timestamp='%s'",
+			teamcityEscape(event.Time.Format(teamcityTimestampFormat))))
+	}
+	sb.WriteString(fmt.Sprintf(" flowId='%s']\n", teamcityEscape(teamcityFlowID(event))))
+	_, _ = io.WriteString(out, sb.String())
+}
+
+// teamcityFlowID returns the package name for a package event, and the package
+// and test name for a test event.
+func teamcityFlowID(event TestEvent) string {
+	if event.PackageEvent() {
+		return event.Package
+	}
+	return event.Package + "." + event.Test
+}
+
+const teamcityTimestampFormat = "2006-01-02T15:04:05.000-0700"
+
+var teamcityReplacer = strings.NewReplacer(
+	"|", "||",
+	"'", "|'",
+	"\n", "|n",
+	"\r", "|r",
+	"[", "|[",
+	"]", "|]",
+	"\u0085", "|x",
+	"\u2028", "|l",
+	"\u2029", "|p",
+)
+
+// teamcityEscape escapes the characters that have special meaning in the
+// value of a service message attribute.
+func teamcityEscape(s string) string {
+	return teamcityReplacer.Replace(s)
+}
+
+func teamcityDuration(elapsed float64) string {
+	if elapsed < 0 {
+		return "0"
+	}
+	return fmt.Sprintf("%d", elapsedDuration(elapsed)/time.Millisecond)
+}
@@ -0,0 +1,51 @@
+package testjson
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestTeamCityEscape(t *testing.T) {
+	actual := teamcityEscape("it's [a|b]\r\n\u0085\u2028\u2029")
+	assert.Equal(t, actual, "it|'s |[a||b|]|r|n|x|l|p")
+}
+
+func TestTeamCityFormat_ParallelTests(t *testing.T) {
+	input := `{"ImportPath": "pkg [pkg.test]", "Action": "build-output", "Output": "# pkg\n"}
+{"Package": "pkg", "Test": "TestA", "Action": "run"}
+{"Package": "pkg", "Test": "TestA", "Action": "pause"}
+{"Package": "pkg", "Test": "TestB", "Action": "run"}
+{"Package": "pkg", "Test": "TestB/sub", "Action": "run"}
+{"Package": "pkg", "Test": "TestA", "Action": "cont"}
+{"Package": "pkg", "Test": "TestA", "Action": "pass"}
+{"Package": "pkg", "Test": "TestB/sub", "Action": "fail"}
+{"Package": "pkg", "Test": "TestB", "Action": "fail"}
+{"Package": "pkg", "Action": "fail"}
+`
+	out := new(bytes.Buffer)
+	shim := &fakeHandler{formatter: teamcityFormat(out), err: new(bytes.Buffer)}
+	_, err := ScanTestOutput(ScanConfig{Stdout: strings.NewReader(input), Handler: shim})
+	assert.NilError(t, err)
+
+	expected := `##teamcity[testSuiteStarted name='pkg' flowId='pkg']
+##teamcity[flowStarted parent='pkg' flowId='pkg.TestA']
+##teamcity[testStarted name='TestA' captureStandardOutput='false' flowId='pkg.TestA']
+##teamcity[flowStarted parent='pkg' flowId='pkg.TestB']
+##teamcity[testStarted name='TestB' captureStandardOutput='false' flowId='pkg.TestB']
+##teamcity[flowStarted parent='pkg' flowId='pkg.TestB/sub']
+##teamcity[testStarted name='TestB/sub' captureStandardOutput='false' flowId='pkg.TestB/sub']
+##teamcity[testFinished name='TestA' duration='0' flowId='pkg.TestA']
+##teamcity[flowFinished flowId='pkg.TestA']
+##teamcity[testFailed name='TestB/sub' message='Failed' details='' flowId='pkg.TestB/sub']
+##teamcity[testFinished name='TestB/sub' duration='0' flowId='pkg.TestB/sub']
+##teamcity[flowFinished flowId='pkg.TestB/sub']
+##teamcity[testFailed name='TestB' message='Failed' details='' flowId='pkg.TestB']
+##teamcity[testFinished name='TestB' duration='0' flowId='pkg.TestB']
+##teamcity[flowFinished flowId='pkg.TestB']
+##teamcity[testSuiteFinished name='pkg' flowId='pkg']
+`
+	assert.Equal(t, out.String(), expected)
+}
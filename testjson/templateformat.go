@@ -0,0 +1,107 @@
+package testjson
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// TemplateData is the data passed to the template used by the formatter
+// returned from NewTemplateFormatter.
+type TemplateData struct {
+	// Event is the event being formatted.
+	Event TestEvent
+	// Package is the package of the event. The results of the event have
+	// already been added to the package.
+	Package *Package
+	// Execution contains the results of all the events received so far.
+	Execution *Execution
+}
+
+// NewTemplateFormatter returns a formatter which prints each event by executing
+// the text/template in text with a TemplateData. The template is responsible
+// for printing any newlines.
+//
+// In addition to the standard template functions, the template can use:
+//
+//	icon ACTION               the icon for a pass, fail, or skip action, using opts.Icons
+//	color ACTION TEXT         TEXT colored by the result of ACTION
+//	red, green, yellow, blue, magenta, cyan, bold TEXT
+//	relativePackagePath PKG   PKG relative to the module in the current directory
+//	duration ELAPSED          the float64 seconds of TestEvent.Elapsed as a time.Duration
+//	seconds DURATION          a time.Duration formatted as seconds, ex: 1.234s
+//	join LINES SEP            strings.Join
+func NewTemplateFormatter(out io.Writer, text string, opts FormatOptions) (EventFormatter, error) {
+	tmpl, err := template.New("format").Funcs(templateFuncs(opts)).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse format template: %w", err)
+	}
+	buf := bufio.NewWriter(out)
+	return eventFormatterFunc(func(event TestEvent, exec *Execution) error {
+		data := TemplateData{
+			Event:     event,
+			Package:   exec.Package(event.Package),
+			Execution: exec,
+		}
+		if err := tmpl.Execute(buf, data); err != nil {
+			return err
+		}
+		return buf.Flush()
+	}), nil
+}
+
+// SummaryTemplate prints the summary of an Execution using a text/template.
+// It is used in place of PrintSummary.
+type SummaryTemplate struct {
+	tmpl *template.Template
+}
+
+// NewSummaryTemplate parses text as a text/template. The template is executed
+// with the *Execution, and can use the same functions as the template used by
+// NewTemplateFormatter.
+func NewSummaryTemplate(text string, opts FormatOptions) (*SummaryTemplate, error) {
+	tmpl, err := template.New("summary").Funcs(templateFuncs(opts)).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse summary template: %w", err)
+	}
+	return &SummaryTemplate{tmpl: tmpl}, nil
+}
+
+// Print the summary of execution to out.
+func (s *SummaryTemplate) Print(out io.Writer, execution *Execution) error {
+	return s.tmpl.Execute(out, execution)
+}
+
+func templateFuncs(opts FormatOptions) template.FuncMap {
+	return template.FuncMap{
+		"icon": getIconFunc(opts),
+		"color": func(action Action, text string) string {
+			return colorEvent(TestEvent{Action: action})("%s", text)
+		},
+		"red":                 colorFunc(color.FgRed),
+		"green":               colorFunc(color.FgGreen),
+		"yellow":              colorFunc(color.FgYellow),
+		"blue":                colorFunc(color.FgBlue),
+		"magenta":             colorFunc(color.FgMagenta),
+		"cyan":                colorFunc(color.FgCyan),
+		"bold":                colorFunc(color.Bold),
+		"relativePackagePath": RelativePackagePath,
+		"duration":            elapsedDuration,
+		"seconds": func(d time.Duration) string {
+			return FormatDurationAsSeconds(d, 3)
+		},
+		"join": strings.Join,
+	}
+}
+
+func colorFunc(attr color.Attribute) func(string) string {
+	c := color.New(attr)
+	return func(text string) string {
+		return c.Sprint(text)
+	}
+}
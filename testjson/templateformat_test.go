@@ -0,0 +1,53 @@
+package testjson
+
+import (
+	"bytes"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/golden"
+)
+
+func TestNewTemplateFormatter(t *testing.T) {
+	patchPkgPathPrefix(t, "gotest.tools/gotestsum")
+
+	text := `{{- if and .Event.Test (or (eq .Event.Action "pass") (eq .Event.Action "fail") (eq .Event.Action "skip")) -}}
+{{ icon .Event.Action }} {{ relativePackagePath .Event.Package }}.{{ .Event.Test }} ({{ seconds (duration .Event.Elapsed) }})
+{{ else if and .Event.PackageEvent (eq .Event.Action "pass" "fail" "skip") -}}
+{{ color .Event.Action (printf "%s" .Event.Action) }} {{ relativePackagePath .Event.Package }} {{ .Package.Total }} tests, {{ len .Package.Failed }} failed
+{{ end -}}`
+	out := new(bytes.Buffer)
+	formatter, err := NewTemplateFormatter(out, text, FormatOptions{Icons: "text"})
+	assert.NilError(t, err)
+
+	shim := newFakeHandler(formatter, "input/go-test-json")
+	_, err = ScanTestOutput(shim.Config(t))
+	assert.NilError(t, err)
+	golden.Assert(t, out.String(), "format/template.out")
+}
+
+func TestNewTemplateFormatter_ParseError(t *testing.T) {
+	_, err := NewTemplateFormatter(new(bytes.Buffer), "{{ .Event", FormatOptions{})
+	assert.ErrorContains(t, err, "failed to parse format template: ")
+}
+
+func TestSummaryTemplate_Print(t *testing.T) {
+	patchPkgPathPrefix(t, "gotest.tools/gotestsum")
+
+	shim := newFakeHandler(noopFormatter{}, "input/go-test-json")
+	exec, err := ScanTestOutput(shim.Config(t))
+	assert.NilError(t, err)
+
+	text := `{{ range .Failed -}}
+--- {{ relativePackagePath .Package }}.{{ .Test }}
+{{ join ($.OutputLines .) "" -}}
+{{ end -}}
+{{ .Total }} tests, {{ len .Failed }} failed, {{ len .Skipped }} skipped
+`
+	summary, err := NewSummaryTemplate(text, FormatOptions{})
+	assert.NilError(t, err)
+
+	out := new(bytes.Buffer)
+	assert.NilError(t, summary.Print(out, exec))
+	golden.Assert(t, out.String(), "format/summary-template.out")
+}
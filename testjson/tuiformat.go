@@ -0,0 +1,488 @@
+package testjson
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fatih/color"
+	"golang.org/x/term"
+	"gotest.tools/gotestsum/internal/dotwriter"
+	"gotest.tools/gotestsum/internal/log"
+	"gotest.tools/gotestsum/internal/tty"
+)
+
+// tuiFormatter redraws a view of the running packages, the running tests,
+// and the failed tests, on a timer. Events only update the state of the view,
+// so that a large number of events does not redraw the view for each one. Key
+// presses select a failure from the list and expand its output.
+//
+// The view is drawn from a snapshot of the Execution taken by Format, because
+// the Execution is modified by another goroutine while the timer redraws the
+// view.
+type tuiFormatter struct {
+	writer *dotwriter.Writer
+	opts   FormatOptions
+	size   func() (width int, height int)
+
+	mu       sync.Mutex
+	started  time.Time
+	pkgs     map[string]*tuiPackage
+	order    []string
+	failures []tuiFailure
+	total    int
+	failed   int
+	skipped  int
+	// testStart is the time each running test started, keyed by package and
+	// test name.
+	testStart map[string]time.Time
+	selected  int
+	scroll    int
+	expanded  map[int]bool
+
+	stop       chan struct{}
+	wg         sync.WaitGroup
+	resetInput func()
+}
+
+type tuiPackage struct {
+	name    string
+	start   time.Time
+	done    bool
+	action  Action
+	elapsed time.Duration
+	cached  bool
+	passed  int
+	failed  int
+	skipped int
+	running []tuiRunningTest
+}
+
+type tuiRunningTest struct {
+	name  string
+	start time.Time
+}
+
+type tuiFailure struct {
+	name   string
+	output []string
+}
+
+var tuiSpinner = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+
+const tuiRedrawInterval = 100 * time.Millisecond
+
+func newTUIFormatter(out io.Writer, opts FormatOptions) EventFormatter {
+	// out may wrap stdout to write colors on Windows, so stdout is used when
+	// out is not a file.
+	fd := int(os.Stdout.Fd())
+	if file, ok := out.(*os.File); ok {
+		fd = int(file.Fd())
+	}
+	if w, h, err := term.GetSize(fd); err != nil || w == 0 || h == 0 {
+		log.Warnf("Failed to detect terminal size for tui format, error: %v", err)
+		return pkgNameFormat(out, opts)
+	}
+	f := newTUI(out, opts, func() (int, int) {
+		w, h, err := term.GetSize(fd)
+		if err != nil {
+			return 80, 24
+		}
+		return w, h
+	})
+	f.start(os.Stdin)
+	return f
+}
+
+func newTUI(out io.Writer, opts FormatOptions, size func() (int, int)) *tuiFormatter {
+	return &tuiFormatter{
+		writer:    dotwriter.New(out),
+		opts:      opts,
+		size:      size,
+		started:   timeNow(),
+		pkgs:      make(map[string]*tuiPackage),
+		testStart: make(map[string]time.Time),
+		expanded:  make(map[int]bool),
+		stop:      make(chan struct{}),
+	}
+}
+
+// start redrawing the view on a timer, and reading key presses from in. Key
+// presses are only read when in is a terminal.
+func (f *tuiFormatter) start(in *os.File) {
+	f.wg.Add(1)
+	go func() {
+		defer f.wg.Done()
+		ticker := time.NewTicker(tuiRedrawInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-f.stop:
+				return
+			case <-ticker.C:
+				f.mu.Lock()
+				_ = f.draw()
+				f.mu.Unlock()
+			}
+		}
+	}()
+
+	// Read with a timeout so that the goroutine can exit when the formatter
+	// is closed.
+	reset, err := tty.SetInputMode(int(in.Fd()), 0, 1)
+	if err != nil {
+		log.Debugf("no terminal input -- keyboard shortcuts disabled: %v", err)
+		return
+	}
+	f.resetInput = reset
+	f.wg.Add(1)
+	go func() {
+		defer f.wg.Done()
+		f.readKeys(in)
+	}()
+}
+
+func (f *tuiFormatter) readKeys(in io.Reader) {
+	buf := make([]byte, 16)
+	for {
+		select {
+		case <-f.stop:
+			return
+		default:
+		}
+		n, err := in.Read(buf)
+		if n > 0 {
+			f.mu.Lock()
+			f.handleKeys(buf[:n])
+			_ = f.draw()
+			f.mu.Unlock()
+		}
+		if err != nil && err != io.EOF {
+			log.Debugf("failed to read input: %v", err)
+			return
+		}
+	}
+}
+
+// handleKeys updates the selected failure, or expands the output of the
+// selected failure.
+func (f *tuiFormatter) handleKeys(keys []byte) {
+	for i := 0; i < len(keys); i++ {
+		key := keys[i]
+		// arrow keys are ESC [ A and ESC [ B
+		if key == 27 && i+2 < len(keys) && keys[i+1] == '[' {
+			key = keys[i+2]
+			i += 2
+			switch key {
+			case 'A':
+				key = 'k'
+			case 'B':
+				key = 'j'
+			}
+		}
+
+		switch key {
+		case 'j':
+			if f.selected < len(f.failures)-1 {
+				f.selected++
+			}
+		case 'k':
+			if f.selected > 0 {
+				f.selected--
+			}
+		case '\r', '\n', ' ':
+			if len(f.failures) > 0 {
+				f.expanded[f.selected] = !f.expanded[f.selected]
+			}
+		}
+	}
+}
+
+func (f *tuiFormatter) Format(event TestEvent, exec *Execution) error {
+	// Events without a package, like build output, are not shown.
+	if event.Package == "" {
+		return nil
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := timeNow()
+	row, ok := f.pkgs[event.Package]
+	if !ok {
+		row = &tuiPackage{name: event.Package, start: now}
+		f.pkgs[event.Package] = row
+		f.order = append(f.order, event.Package)
+	}
+	pkg := exec.Package(event.Package)
+
+	key := event.Package + "\x00" + event.Test
+	switch {
+	case event.Action == ActionRun:
+		f.testStart[key] = now
+	case event.Action.IsTerminal() && !event.PackageEvent():
+		delete(f.testStart, key)
+	}
+
+	switch event.Action {
+	case ActionOutput, ActionBench:
+		return nil
+	case ActionFail:
+		f.addFailure(event, pkg)
+	}
+
+	if event.PackageEvent() && event.Action.IsTerminal() {
+		row.done = true
+		row.action = event.Action
+		if event.Action == ActionPass && pkg.Total == 0 {
+			row.action = ActionSkip
+		}
+		row.elapsed = pkg.Elapsed()
+		row.cached = pkg.cached
+	}
+	row.passed, row.failed, row.skipped = len(pkg.Passed), len(pkg.Failed), len(pkg.Skipped)
+	row.running = f.runningTests(event.Package, pkg)
+
+	f.total = exec.Total()
+	f.failed = len(exec.Failed())
+	f.skipped = len(exec.Skipped())
+	return nil
+}
+
+func (f *tuiFormatter) addFailure(event TestEvent, pkg *Package) {
+	switch {
+	case !event.PackageEvent():
+		tc := pkg.LastFailedByName(event.Test)
+		f.failures = append(f.failures, tuiFailure{
+			name:   RelativePackagePath(event.Package) + "." + tc.Test.Name() + formatRunID(tc.RunID),
+			output: pkg.OutputLines(tc),
+		})
+	case pkg.TestMainFailed():
+		f.failures = append(f.failures, tuiFailure{
+			name:   RelativePackagePath(event.Package),
+			output: pkg.OutputLines(TestCase{}),
+		})
+	}
+}
+
+// runningTests returns the running tests in the package which have no running
+// subtests, in the order they started.
+func (f *tuiFormatter) runningTests(pkgName string, pkg *Package) []tuiRunningTest {
+	tcs := make([]TestCase, 0, len(pkg.running))
+	for _, tc := range pkg.running {
+		tcs = append(tcs, tc)
+	}
+	sort.Slice(tcs, func(i, j int) bool {
+		return tcs[i].ID < tcs[j].ID
+	})
+
+	var result []tuiRunningTest
+	for _, tc := range tcs {
+		name := tc.Test.Name()
+		if hasRunningSubTest(pkg, name) {
+			continue
+		}
+		start, ok := f.testStart[pkgName+"\x00"+name]
+		if !ok {
+			start = timeNow()
+		}
+		result = append(result, tuiRunningTest{name: name, start: start})
+	}
+	return result
+}
+
+func hasRunningSubTest(pkg *Package, name string) bool {
+	for other := range pkg.running {
+		if strings.HasPrefix(other, name+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// draw the view. f.mu must be held by the caller.
+func (f *tuiFormatter) draw() error {
+	width, height := f.size()
+	now := timeNow()
+	spinner := tuiSpinner[int(now.UnixMilli()/tuiRedrawInterval.Milliseconds())%len(tuiSpinner)]
+
+	pkgLines := f.packageLines(now, spinner, width)
+	failureLines, selectedLine := f.failureLines(width)
+
+	// One line is used by the status line.
+	avail := height - 1
+	if len(pkgLines)+len(failureLines) > avail {
+		failureHeight := min(len(failureLines), max(avail/2, avail-len(pkgLines)))
+		failureLines = f.scrollFailures(failureLines, selectedLine, failureHeight)
+
+		pkgHeight := avail - len(failureLines)
+		if hidden := len(pkgLines) - pkgHeight; hidden > 0 && pkgHeight > 0 {
+			pkgLines = pkgLines[hidden+1:]
+			pkgLines = append([]string{fmt.Sprintf("  (%d more lines)", hidden+1)}, pkgLines...)
+		}
+	}
+
+	for _, line := range pkgLines {
+		fmt.Fprintln(f.writer, line)
+	}
+	for _, line := range failureLines {
+		fmt.Fprintln(f.writer, line)
+	}
+	fmt.Fprintln(f.writer, f.statusLine(now, spinner, width))
+	return f.writer.Flush()
+}
+
+func (f *tuiFormatter) packageLines(now time.Time, spinner string, width int) []string {
+	getIcon := getIconFunc(f.opts)
+
+	// completed packages are listed first, in the order they completed,
+	// followed by running packages in the order they started.
+	var done, running []string
+	for _, name := range f.order {
+		row := f.pkgs[name]
+		if row.done {
+			done = append(done, name)
+		} else {
+			running = append(running, name)
+		}
+	}
+
+	var lines []string
+	for _, name := range done {
+		row := f.pkgs[name]
+		if f.opts.HideEmptyPackages && row.action == ActionSkip {
+			continue
+		}
+		elapsed := FormatDurationAsSeconds(row.elapsed, 1)
+		if row.cached {
+			elapsed = "cached"
+		}
+		lines = append(lines, getIcon(row.action)+" "+
+			truncate(fmt.Sprintf("%7s %s%s", elapsed, RelativePackagePath(name), f.counts(row)), width-2))
+	}
+	for _, name := range running {
+		row := f.pkgs[name]
+		elapsed := FormatDurationAsSeconds(now.Sub(row.start), 1)
+		lines = append(lines, color.CyanString(spinner)+" "+
+			truncate(fmt.Sprintf("%7s %s%s", elapsed, RelativePackagePath(name), f.counts(row)), width-2))
+		for _, test := range row.running {
+			elapsed := FormatDurationAsSeconds(now.Sub(test.start), 1)
+			lines = append(lines, truncate(fmt.Sprintf("          %7s %s", elapsed, test.name), width))
+		}
+	}
+	return lines
+}
+
+func (f *tuiFormatter) counts(row *tuiPackage) string {
+	var buf strings.Builder
+	for _, c := range []struct {
+		name  string
+		count int
+	}{
+		{name: "passed", count: row.passed},
+		{name: "failed", count: row.failed},
+		{name: "skipped", count: row.skipped},
+	} {
+		if c.count > 0 {
+			buf.WriteString(fmt.Sprintf(" %d %s", c.count, c.name))
+		}
+	}
+	if buf.Len() == 0 {
+		return ""
+	}
+	return " (" + strings.TrimPrefix(buf.String(), " ") + ")"
+}
+
+// failureLines returns the lines of the failure pane, and the index of the
+// line of the selected failure.
+func (f *tuiFormatter) failureLines(width int) ([]string, int) {
+	if len(f.failures) == 0 {
+		return nil, 0
+	}
+	lines := []string{
+		"",
+		color.RedString(truncate(fmt.Sprintf(
+			"Failures (%d) up/down or j/k to select, enter to show output", len(f.failures)), width)),
+	}
+	var selectedLine int
+	for i, failure := range f.failures {
+		marker := "  "
+		if i == f.selected {
+			marker = "> "
+			selectedLine = len(lines)
+		}
+		lines = append(lines, marker+color.RedString(truncate(failure.name, width-2)))
+		if !f.expanded[i] {
+			continue
+		}
+		for _, line := range failure.output {
+			lines = append(lines, truncate("    "+strings.TrimRight(line, "\n"), width))
+		}
+	}
+	return lines, selectedLine
+}
+
+// scrollFailures returns height lines from the failure pane, scrolled so that
+// the selected failure is visible. The header lines are always visible.
+func (f *tuiFormatter) scrollFailures(lines []string, selectedLine int, height int) []string {
+	const header = 2
+	if height <= header {
+		return lines[:max(height, 0)]
+	}
+	body := height - header
+	selected := selectedLine - header
+	switch {
+	case selected < f.scroll:
+		f.scroll = selected
+	case selected >= f.scroll+body:
+		f.scroll = selected - body + 1
+	}
+	f.scroll = max(min(f.scroll, len(lines)-header-body), 0)
+	return append(lines[:header:header], lines[header+f.scroll:header+f.scroll+body]...)
+}
+
+func (f *tuiFormatter) statusLine(now time.Time, spinner string, width int) string {
+	status := fmt.Sprintf("%d tests%s%s in %s",
+		f.total,
+		formatTestCount(f.skipped, "skipped", ""),
+		formatTestCount(f.failed, "failure", "s"),
+		FormatDurationAsSeconds(now.Sub(f.started), 1))
+	return color.CyanString(spinner) + " " + truncate(status, width-2)
+}
+
+// Close stops redrawing the view, resets the terminal, and draws the list of
+// packages one last time. The failures are omitted, because they are printed
+// by the summary.
+func (f *tuiFormatter) Close() error {
+	close(f.stop)
+	f.wg.Wait()
+	if f.resetInput != nil {
+		f.resetInput()
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	width, _ := f.size()
+	for _, line := range f.packageLines(timeNow(), " ", width) {
+		fmt.Fprintln(f.writer, line)
+	}
+	return f.writer.Flush()
+}
+
+// truncate s to width runes.
+func truncate(s string, width int) string {
+	if width <= 0 {
+		return ""
+	}
+	runes := []rune(s)
+	if len(runes) <= width {
+		return s
+	}
+	if width == 1 {
+		return "…"
+	}
+	return string(runes[:width-1]) + "…"
+}
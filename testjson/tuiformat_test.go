@@ -0,0 +1,95 @@
+package testjson
+
+import (
+	"bytes"
+	"regexp"
+	"testing"
+	"time"
+
+	"gotest.tools/gotestsum/internal/dotwriter"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/golden"
+)
+
+func TestTUIFormatter(t *testing.T) {
+	patchPkgPathPrefix(t, "example.com")
+	now := time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC)
+	patchTimeNow(t, now)
+
+	height := 30
+	out := new(bytes.Buffer)
+	f := newTUI(out, FormatOptions{Icons: "text"}, func() (int, int) { return 60, height })
+	exec := newExecution()
+
+	send := func(d time.Duration, event TestEvent) {
+		t.Helper()
+		now = now.Add(d)
+		patchTimeNow(t, now)
+		exec.add(event)
+		assert.NilError(t, f.Format(event, exec))
+	}
+	send(0, TestEvent{Package: "example.com/done", Action: ActionRun, Test: "TestOne"})
+	send(time.Second, TestEvent{Package: "example.com/done", Action: ActionPass, Test: "TestOne"})
+	send(0, TestEvent{Package: "example.com/done", Action: ActionPass, Elapsed: 1})
+	send(0, TestEvent{Package: "example.com/slow", Action: ActionRun, Test: "TestHangs"})
+	send(0, TestEvent{Package: "example.com/slow", Action: ActionRun, Test: "TestParent"})
+	send(time.Second, TestEvent{Package: "example.com/slow", Action: ActionRun, Test: "TestParent/sub"})
+	send(0, TestEvent{Package: "example.com/slow", Action: ActionRun, Test: "TestFails"})
+	send(0, TestEvent{Package: "example.com/slow", Action: ActionOutput, Test: "TestFails",
+		Output: "    slow_test.go:12: something went wrong\n"})
+	send(0, TestEvent{Package: "example.com/slow", Action: ActionFail, Test: "TestFails"})
+	send(0, TestEvent{Package: "example.com/slow", Action: ActionRun, Test: "TestAlsoFails"})
+	send(0, TestEvent{Package: "example.com/slow", Action: ActionOutput, Test: "TestAlsoFails",
+		Output: "    slow_test.go:20: also wrong\n"})
+	send(0, TestEvent{Package: "example.com/slow", Action: ActionFail, Test: "TestAlsoFails"})
+	// the view is only drawn by the timer
+	assert.Equal(t, out.Len(), 0)
+	now = now.Add(2500 * time.Millisecond)
+	patchTimeNow(t, now)
+
+	draw := func() string {
+		t.Helper()
+		out.Reset()
+		f.writer = dotwriter.New(out)
+		assert.NilError(t, f.draw())
+		return stripEscapeSequences(out.String())
+	}
+
+	t.Run("running", func(t *testing.T) {
+		golden.Assert(t, draw(), "format/tui-running.out")
+	})
+
+	t.Run("expand selected failure", func(t *testing.T) {
+		f.handleKeys([]byte{'j', '\n'})
+		golden.Assert(t, draw(), "format/tui-expanded.out")
+
+		// arrow keys
+		f.handleKeys([]byte{27, '[', 'A', 27, '[', 'A'})
+		assert.Equal(t, f.selected, 0)
+	})
+
+	t.Run("scroll failures", func(t *testing.T) {
+		height = 8
+		golden.Assert(t, draw(), "format/tui-scroll.out")
+	})
+
+	t.Run("close", func(t *testing.T) {
+		out.Reset()
+		f.writer = dotwriter.New(out)
+		assert.NilError(t, f.Close())
+		golden.Assert(t, stripEscapeSequences(out.String()), "format/tui-close.out")
+	})
+}
+
+var escapeSequence = regexp.MustCompile("\x1b\\[[0-9?]*[a-zA-Z]")
+
+func stripEscapeSequences(s string) string {
+	return escapeSequence.ReplaceAllString(s, "")
+}
+
+func TestTruncate(t *testing.T) {
+	assert.Equal(t, truncate("abcdef", 10), "abcdef")
+	assert.Equal(t, truncate("abcdef", 4), "abc…")
+	assert.Equal(t, truncate("abcdef", 1), "…")
+	assert.Equal(t, truncate("abcdef", 0), "")
+}